@@ -0,0 +1,84 @@
+package clean
+
+import (
+	"fmt"
+	"pmanager/conf"
+	"pmanager/database"
+	"pmanager/log"
+	"pmanager/util/conv"
+	"time"
+)
+
+var (
+	// DryRun only lists the orphaned files (default behaviour).
+	DryRun bool
+
+	// Apply removes the orphaned files
+	// (or moves them to MoveTo if set), unless DryRun is set.
+	Apply bool
+
+	// OlderThan is the minimal age in days of the files to clean.
+	OlderThan int
+
+	// MoveTo is the archive directory where the files are moved
+	// instead of being removed.
+	MoveTo string
+)
+
+func Exec() {
+	database.Load(conf.String("database.uri"))
+
+	base := conf.String("repository.basedir")
+	files, skipped, err := database.FindOrphanFiles(
+		base,
+		conf.String("repository.extension"),
+		conf.Slice("repository.include"),
+		conf.Slice("repository.exclude"),
+		conf.Slice("repository.architectures"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to read the repositories: %s\n", err)
+	}
+
+	files = filterOlder(files, time.Now().AddDate(0, 0, -OlderThan))
+	apply := Apply && !DryRun
+
+	var (
+		total, cleaned int64
+		failed         int
+		repos          = make(map[string]int64)
+	)
+	for _, f := range files {
+		fmt.Printf("\033[1;32m%s\033[m (%s, %s)\n", f.Path, conv.ToSize(f.Size), f.ModTime.Format(time.RFC1123))
+		total += f.Size
+		repos[f.Repository+"/"+f.Arch] += f.Size
+
+		if !apply {
+			continue
+		}
+		if err := cleanFile(base, f); err != nil {
+			fmt.Printf("\033[1;31mFailed to clean %s: %s\033[m\n", f.Path, err)
+			failed++
+		} else {
+			cleaned += f.Size
+		}
+	}
+
+	for _, r := range skipped {
+		fmt.Printf("\033[1;33m%s skipped: unreadable repository database or no package referenced\033[m\n", r)
+	}
+
+	fmt.Println()
+	for _, r := range sortedKeys(repos) {
+		fmt.Printf("\033[1m%s:\033[m %s\n", r, conv.ToSize(repos[r]))
+	}
+
+	switch {
+	case !apply:
+		fmt.Printf("\033[1m%d orphaned file(s), %s reclaimable\033[m (dry run, use --apply to clean)\n", len(files), conv.ToSize(total))
+	case MoveTo != "":
+		fmt.Printf("\033[1m%d file(s) moved to %s, %s reclaimed\033[m\n", len(files)-failed, MoveTo, conv.ToSize(cleaned))
+	default:
+		fmt.Printf("\033[1m%d file(s) removed, %s reclaimed\033[m\n", len(files)-failed, conv.ToSize(cleaned))
+	}
+}
@@ -0,0 +1,99 @@
+package clean
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"pmanager/database"
+	"sort"
+	"time"
+)
+
+func filterOlder(files []database.OrphanFile, limit time.Time) []database.OrphanFile {
+	var out []database.OrphanFile
+	for _, f := range files {
+		if f.ModTime.Before(limit) {
+			out = append(out, f)
+		}
+	}
+
+	return out
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// copyFile copies the file to a new file.
+// It fails if the destination already exists,
+// and removes the incomplete destination on failure.
+func copyFile(src, dest string) (err error) {
+	var (
+		in, out *os.File
+		fi      os.FileInfo
+	)
+	if in, err = os.Open(src); err != nil {
+		return
+	}
+	defer in.Close()
+	if fi, err = in.Stat(); err != nil {
+		return
+	}
+
+	if out, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm()); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(dest)
+		}
+	}()
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	if err = out.Close(); err != nil {
+		return
+	}
+
+	return os.Chtimes(dest, fi.ModTime(), fi.ModTime())
+}
+
+// moveFile moves the file, copying it
+// if the destination is on another device.
+// An existing destination is never overwritten.
+func moveFile(src, dest string) error {
+	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if os.Rename(src, dest) == nil {
+		return nil
+	}
+	if err := copyFile(src, dest); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+func cleanFile(base string, f database.OrphanFile) error {
+	src := path.Join(base, f.Path)
+	if MoveTo != "" {
+		return moveFile(src, path.Join(MoveTo, f.Path))
+	}
+
+	return os.Remove(src)
+}
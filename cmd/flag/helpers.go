@@ -8,7 +8,7 @@ import (
 	"pmanager/log"
 	"pmanager/util/conv"
 	"pmanager/util/resource"
-	"strconv"
+	"pmanager/util/shell"
 	"strings"
 )
 
@@ -103,47 +103,12 @@ func deleteFlags(ids []uint) int {
 	return deleteOffline(ids)
 }
 
-func getRange(arg string, c int) (rg []int) {
-	if arg == "all" {
-		rg = make([]int, c)
-		for i := range rg {
-			rg[i] = i
-		}
-		return
-	}
-
-	srg := strings.Split(arg, ",")
-	for _, e := range srg {
-		r := strings.SplitN(e, "-", 2)
-		if len(r) == 1 {
-			if i, err := strconv.Atoi(r[0]); err == nil && i > 0 && i <= c {
-				rg = append(rg, i-1)
-			}
-		} else {
-			i1, e1 := strconv.Atoi(r[0])
-			i2, e2 := strconv.Atoi(r[1])
-			if e1 == nil && e2 == nil {
-				if i1 > i2 {
-					i1, i2 = i2, i1
-				}
-				for i := i1; i <= i2; i++ {
-					if i > 0 && i <= c {
-						rg = append(rg, i-1)
-					}
-				}
-			}
-		}
-	}
-
-	return
-}
-
 func getIds(args []string, flags []database.Flag) (ids []uint) {
 	c := len(flags)
 	done := make(map[int]bool)
 
 	for _, e := range args {
-		rg := getRange(e, c)
+		rg := shell.GetRange(e, c)
 		for _, i := range rg {
 			if !done[i] {
 				done[i] = true
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+	"pmanager/conf"
+	"pmanager/database"
+	"pmanager/log"
+	"pmanager/util/graph"
+	"strings"
+)
+
+var (
+	// Name is the package of the graph (<repo>/<pkgname>).
+	Name string
+
+	// Arch is the architecture of the repository of the package.
+	// If empty, the first package found is used.
+	Arch string
+
+	// Depth is the maximal depth of the graph (negative for no limit).
+	Depth = 1
+
+	// Kinds are the kinds of dependencies to follow.
+	Kinds = []string{database.DependKind}
+
+	// Format is the output format (dot, graphml or json).
+	Format = graph.Dot
+)
+
+func Exec() {
+	repo, name, ok := strings.Cut(Name, "/")
+	if !ok || repo == "" || name == "" {
+		fmt.Fprintln(os.Stderr, "\033[1;31mThe package must be given as <repo>/<pkgname>\033[m")
+		os.Exit(1)
+	}
+	if !graph.IsFormat(Format) {
+		fmt.Fprintf(os.Stderr, "\033[1;31mUnknown format %s\033[m\n", Format)
+		os.Exit(1)
+	}
+	if !database.IsDependKinds(Kinds) {
+		fmt.Fprintf(os.Stderr, "\033[1;31mUnknown kinds of dependencies %s\033[m\n", strings.Join(Kinds, ","))
+		os.Exit(1)
+	}
+
+	database.Load(conf.String("database.uri"))
+
+	g, ok := database.BuildGraph(repo, Arch, name, Depth, Kinds)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "\033[1;31mPackage %s not found\033[m\n", Name)
+		os.Exit(1)
+	}
+
+	if err := g.Write(os.Stdout, Format, true); err != nil {
+		log.Fatalln(err)
+	}
+}
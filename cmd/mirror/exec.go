@@ -0,0 +1,55 @@
+package mirror
+
+import (
+	"fmt"
+	"pmanager/util/shell"
+	"time"
+)
+
+func Exec() {
+	var applications []application
+
+	for {
+		args := shell.Prompt("> ")
+		if len(args) == 0 {
+			fmt.Println("Type help for usage")
+		} else {
+			switch args[0] {
+			case "help":
+				fmt.Print(help)
+			case "quit":
+				return
+			case "list":
+				applications = listApplications()
+				for i, a := range applications {
+					date := a.CreatedAt.Format(time.RFC1123)
+					validated := "\033[1;31mnot validated\033[m"
+					if a.Validated {
+						validated = "\033[1;32mvalidated\033[m"
+					}
+					fmt.Printf("\033[1;36m%d\033[m → \033[1;32m%s\033[m (%s)\n", i+1, a.URL, validated)
+					fmt.Printf("\033[1mDate:    \033[m %s\n", date)
+					fmt.Printf("\033[1mCountry: \033[m %s\n", a.Country)
+					fmt.Printf("\033[1mOperator:\033[m %s <%s>\n", a.Operator, a.Email)
+					fmt.Printf("\033[1mChecks:  \033[m %d/%d successful\n", a.Successes, a.Checks)
+					if a.LastError != "" {
+						fmt.Printf("\033[1mError:   \033[m %s\n", a.LastError)
+					}
+					fmt.Printf("\033[1mComment: \033[m %s\n", a.Comment)
+				}
+			case "approve":
+				ids := getIds(args[1:], applications)
+				c := approveApplications(ids)
+				fmt.Printf("%d application(s) approved\n", c)
+				applications = nil
+			case "reject":
+				ids := getIds(args[1:], applications)
+				c := rejectApplications(ids)
+				fmt.Printf("%d application(s) rejected\n", c)
+				applications = nil
+			default:
+				fmt.Printf("Command “%s” unknown. Type help for usage\n", args[0])
+			}
+		}
+	}
+}
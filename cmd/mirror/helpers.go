@@ -0,0 +1,151 @@
+package mirror
+
+import (
+	"fmt"
+	"net/http"
+	"pmanager/conf"
+	"pmanager/database"
+	"pmanager/log"
+	"pmanager/util/conv"
+	"pmanager/util/resource"
+	"pmanager/util/shell"
+	"strings"
+)
+
+const help = `Available commands:
+  help                           display this help
+  list                           list the pending mirror applications
+  approve (all|<range id>)       approve selected applications and add them to the managed mirrorlist
+                                 (needs to launch list before, only validated applications are approved)
+  reject (all|<range id>)        reject selected applications (needs to launch list before)
+  quit                           exit the prompt
+
+Range id formating:
+  1,2,8:      select the applications with an id 1, 2 or 8
+  2-5:        select the applications with an id between 2 and 5
+  5-2:        same as 2-5
+  1,4-5,18,2: mixing range and discrete values
+`
+
+type application struct {
+	database.MirrorApplication
+	Validated bool
+}
+
+func isOnline() (port string, ok bool) {
+	port = conf.String("api.port")
+	ok = resource.IsPortOpen("localhost", port)
+
+	return
+}
+
+func callApi(port, route string, ids []uint) conv.Map {
+	sids := make([]string, len(ids))
+	for i, id := range ids {
+		sids[i] = fmt.Sprint(id)
+	}
+
+	url := fmt.Sprintf("http://localhost:%s/mirror/application/%s?ids=%s", port, route, strings.Join(sids, ","))
+	data, err := http.Get(url)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	m := make(conv.Map)
+	if data.Body != nil {
+		defer data.Body.Close()
+		conv.ReadJson(data.Body, &m)
+	}
+
+	return m
+}
+
+func listOffline() (applications []application) {
+	var apps []database.MirrorApplication
+	database.Search(
+		&apps,
+		database.NewRequest(
+			[]database.Filter{database.NewFilter("status", "=", database.ApplicationPending)},
+			[]database.Sort{database.NewSort("created_at", false)},
+		),
+	)
+
+	applications = make([]application, len(apps))
+	for i, a := range apps {
+		applications[i] = application{
+			MirrorApplication: a,
+			Validated:         a.IsValidated(),
+		}
+	}
+
+	return
+}
+
+func listOnline(port string) (applications []application) {
+	url := fmt.Sprintf("http://localhost:%s/mirror/application/list", port)
+	data, err := http.Get(url)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if data.Body != nil {
+		defer data.Body.Close()
+		m := make(conv.Map)
+		conv.ReadJson(data.Body, &m)
+		if d, ok := m["data"]; ok {
+			if err := conv.ToData(d, &applications); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+
+	return
+}
+
+func listApplications() []application {
+	if port, ok := isOnline(); ok {
+		return listOnline(port)
+	}
+
+	return listOffline()
+}
+
+func approveApplications(ids []uint) int {
+	if port, ok := isOnline(); ok {
+		return int(callApi(port, "approve", ids).GetInt("applications_approved"))
+	}
+
+	c, err := database.ApproveMirrorApplications(ids, conf.String("mirror.managed_mirrorlist"))
+	if err != nil {
+		log.Errorf("Failed to approve mirror applications: %s\n", err)
+	}
+
+	return c
+}
+
+func rejectApplications(ids []uint) int {
+	if port, ok := isOnline(); ok {
+		return int(callApi(port, "reject", ids).GetInt("applications_rejected"))
+	}
+
+	return database.RejectMirrorApplications(ids)
+}
+
+func getIds(args []string, applications []application) (ids []uint) {
+	c := len(applications)
+	done := make(map[int]bool)
+
+	for _, e := range args {
+		rg := shell.GetRange(e, c)
+		for _, i := range rg {
+			if !done[i] {
+				done[i] = true
+				ids = append(ids, applications[i].ID)
+			}
+		}
+	}
+
+	return
+}
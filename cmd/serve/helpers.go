@@ -2,17 +2,29 @@ package serve
 
 import (
 	"fmt"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"pmanager/conf"
 	"pmanager/database"
 	"pmanager/log"
 	"pmanager/util/conv"
+	"pmanager/util/geoip"
 	"pmanager/util/mail"
+	"pmanager/util/metalink"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-func getMailSubjectAndBody(p database.Package, cr string) (subject, body string) {
+// getMailSubjectAndBody returns the notification of the flagged packages.
+// If several packages are flagged (split packages of a same pkgbase),
+// they are grouped in one notification.
+func getMailSubjectAndBody(packages []database.Package, cr string) (subject, body string) {
+	p := packages[0]
 	pname := p.FullName()
 	comment := p.Flag.Comment
 
@@ -26,20 +38,31 @@ func getMailSubjectAndBody(p database.Package, cr string) (subject, body string)
 		"",
 		"---",
 		fmt.Sprintf("The package %s has been flagged as outdated.", pname),
-		"by: " + p.Flag.Email,
+	}
+	subject = fmt.Sprintf("The package %s has been flagged as outdated", pname)
+
+	if len(packages) > 1 {
+		base := fmt.Sprintf("%s/%s-%s", p.Repository, p.PkgBase(), p.Version)
+		bodyLines[4] = fmt.Sprintf("The pkgbase %s has been flagged as outdated, with its packages:", base)
+		for _, sp := range packages {
+			bodyLines = append(bodyLines, "  - "+sp.FullName())
+		}
+		subject = fmt.Sprintf("The pkgbase %s has been flagged as outdated", base)
+	}
+
+	bodyLines = append(bodyLines,
+		"by: "+p.Flag.Email,
 		"",
 		"Additional informations:",
 		comment,
-	}
-
-	subject = fmt.Sprintf("The package %s has been flagged as outdated", pname)
+	)
 	body = strings.Join(bodyLines, cr)
 
 	return
 }
 
-func sendMail(p database.Package) {
-	subject, body := getMailSubjectAndBody(p, "\r\n")
+func sendMail(packages ...database.Package) {
+	subject, body := getMailSubjectAndBody(packages, "\r\n")
 	var m mail.Mail
 
 	m.From(conf.String("smtp.send_from")).
@@ -57,6 +80,37 @@ func sendMail(p database.Package) {
 	}
 }
 
+func sendApplicationMail(a database.MirrorApplication) {
+	bodyLines := []string{
+		fmt.Sprintf("A new mirror has been proposed: %s", a.URL),
+		"",
+		"Country:  " + a.Country,
+		"Operator: " + a.Operator,
+		"Email:    " + a.Email,
+		"",
+		"Additional informations:",
+		strings.ReplaceAll(a.Comment, "\n", "\r\n"),
+		"",
+		"---",
+		"The mirror will be checked during the probation period before its approval.",
+	}
+	var m mail.Mail
+
+	m.From(conf.String("smtp.send_from")).
+		To(conf.String("smtp.send_to")).
+		Header("Reply-To", a.Email).
+		Header("X-Mailer", "Packages").
+		Header("MIME-Version", "1.0").
+		Header("Content-Transfer-Encoding", "8bit").
+		Header("Content-type", "text/plain; charset=utf-8").
+		Subject(fmt.Sprintf("New mirror application: %s", a.URL)).
+		Body(strings.Join(bodyLines, "\r\n"))
+
+	if err := mail.Send(m); err != nil {
+		log.Errorf("Failed to send mail: %s\n", err)
+	}
+}
+
 func debugRequest(r *http.Request, code int) {
 	log.Debugf("%s %s (%d) %s %s\n", r.Method, r.RequestURI, code, r.RemoteAddr, r.Header.Get("user-agent"))
 }
@@ -81,6 +135,21 @@ func writeResponse(r *http.Request, w http.ResponseWriter, data any, codes ...in
 	}
 }
 
+// writeUpdateResult writes the result of an update.
+// The HTTP status is 207 if the update partially failed
+// and 500 if it totally failed.
+func writeUpdateResult(r *http.Request, w http.ResponseWriter, res *database.UpdateResult) {
+	code := http.StatusOK
+	switch res.Status() {
+	case database.UpdatePartial:
+		code = http.StatusMultiStatus
+	case database.UpdateFailure:
+		code = http.StatusInternalServerError
+	}
+
+	writeResponse(r, w, res.Map(), code)
+}
+
 func getString(r *http.Request, key string) string { return r.FormValue(key) }
 
 func getInt(r *http.Request, key string) int64 { return conv.String2Int(getString(r, key)) }
@@ -89,6 +158,39 @@ func getBool(r *http.Request, key string) bool { return conv.String2Bool(getStri
 
 func getDate(r *http.Request, key string) time.Time { return conv.String2Date(getString(r, key)) }
 
+// getStrings returns the values of the key,
+// given as a comma-separated list or repeated in the query.
+func getStrings(r *http.Request, key string) (values []string) {
+	if r.Form == nil {
+		r.ParseForm()
+	}
+
+	for _, v := range r.Form[key] {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				values = append(values, e)
+			}
+		}
+	}
+
+	return
+}
+
+func getIds(r *http.Request, key string) ([]uint, error) {
+	sids := strings.Split(getString(r, key), ",")
+	ids := make([]uint, len(sids))
+
+	for i, sid := range sids {
+		id, err := strconv.ParseUint(sid, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = uint(id)
+	}
+
+	return ids, nil
+}
+
 func initPaginationQuery(r *http.Request) *database.Request {
 	page := getInt(r, "page")
 	if page <= 0 {
@@ -153,7 +255,7 @@ func getSort(r *http.Request, authorized_fields ...string) conv.Map {
 func getPackages(w http.ResponseWriter, r *http.Request, repository string) {
 	q := initPaginationQuery(r)
 	ms := getSort(r, "name", "repo", "date", "flagged")
-	mf := getFilter(r, "search", "from|d", "to|d", "flagged|b", "exact|b")
+	mf := getFilter(r, "search", "arch", "group", "from|d", "to|d", "flagged|b", "exact|b")
 
 	if repository != "" {
 		mf["repo"] = repository
@@ -179,6 +281,14 @@ func getPackages(w http.ResponseWriter, r *http.Request, repository string) {
 		q.AddFilter("repository", "=", repository)
 	}
 
+	if mf.Exists("arch") {
+		q.AddFilter("repo_arch", "=", mf.GetString("arch"))
+	}
+
+	if mf.Exists("group") {
+		q.AddFilter(database.GroupCondition, "", mf.GetString("group"))
+	}
+
 	if mf.Exists("flagged") {
 		op := "="
 		if mf.GetBool("flagged") {
@@ -228,6 +338,7 @@ func getPackages(w http.ResponseWriter, r *http.Request, repository string) {
 			"Md5Sum":        p.Md5Sum,
 			"Sha256Sum":     p.Sha256Sum,
 			"Filename":      p.Filename,
+			"RepoArch":      p.RepoArch,
 			"BuildDate":     p.BuildDate,
 			"Flagged":       p.FlagID != 0,
 			"CompleteName":  p.VersionName(),
@@ -243,3 +354,264 @@ func getPackages(w http.ResponseWriter, r *http.Request, repository string) {
 		"size":     conv.ToSize(totalSize),
 	})
 }
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the IP of the client.
+// If the request comes from a trusted proxy,
+// the address is taken from the X-Forwarded-For header.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		fip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if fip == nil {
+			break
+		}
+		if ip = fip; !isTrustedProxy(ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+var (
+	applyMtx  sync.Mutex
+	lastApply = make(map[string]time.Time)
+)
+
+// allowApplication returns true if the client didn’t apply
+// for a mirror since mirror.apply_delay minutes,
+// and records the new application.
+func allowApplication(r *http.Request) bool {
+	delay := time.Duration(conf.Int("mirror.apply_delay")) * time.Minute
+	if delay <= 0 {
+		return true
+	}
+
+	ip := clientIP(r).String()
+	now := time.Now()
+
+	applyMtx.Lock()
+	defer applyMtx.Unlock()
+
+	for k, t := range lastApply {
+		if now.Sub(t) >= delay {
+			delete(lastApply, k)
+		}
+	}
+	if _, ok := lastApply[ip]; ok {
+		return false
+	}
+	lastApply[ip] = now
+
+	return true
+}
+
+func isSynced(m database.Mirror) bool {
+	if !m.Online || len(m.Repos) == 0 {
+		return false
+	}
+
+	for _, r := range m.Repos {
+		if !r.Sync {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isRepoSynced(m database.Mirror, repo, arch string) bool {
+	if !m.Online {
+		return false
+	}
+
+	for _, r := range m.Repos {
+		if r.Name == repo && (arch == "" || r.Arch == arch) {
+			return r.Sync
+		}
+	}
+
+	return false
+}
+
+const (
+	matchCountry = iota
+	matchContinent
+	matchNone
+)
+
+var matches = []string{"country", "continent", "none"}
+
+type mirrorCandidate struct {
+	database.Mirror
+	match int
+}
+
+// rankMirrors returns the accepted mirrors,
+// sorted by proximity with the given location:
+// first the mirrors of the same country, then of the same continent.
+func rankMirrors(mirrors []database.Mirror, loc geoip.Location, accept func(database.Mirror) bool) (candidates []mirrorCandidate) {
+	for _, m := range mirrors {
+		if !accept(m) {
+			continue
+		}
+		c := mirrorCandidate{Mirror: m, match: matchNone}
+		if loc.Country != "" && m.CountryCode == loc.Country {
+			c.match = matchCountry
+		} else if loc.Continent != "" && m.ContinentCode == loc.Continent {
+			c.match = matchContinent
+		}
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].match < candidates[j].match
+	})
+
+	return
+}
+
+// nearestMirrors returns the online and synced mirrors
+// nearest to the given location.
+func nearestMirrors(mirrors []database.Mirror, loc geoip.Location, limit int64) []conv.Map {
+	candidates := rankMirrors(mirrors, loc, isSynced)
+	if limit > 0 && int64(len(candidates)) > limit {
+		candidates = candidates[:limit]
+	}
+
+	data := make([]conv.Map, len(candidates))
+	for i, c := range candidates {
+		data[i] = conv.Map{
+			"Name":          c.Name,
+			"CountryCode":   c.CountryCode,
+			"ContinentCode": c.ContinentCode,
+			"Match":         matches[c.match],
+		}
+	}
+
+	return data
+}
+
+// downloadMirror returns the URL of the repository of the package
+// on a synced mirror, choosen randomly among the nearest ones.
+// If no mirror is found, it returns the URL of the main repository.
+func downloadMirror(mirrors []database.Mirror, loc geoip.Location, p database.Package) string {
+	candidates := rankMirrors(mirrors, loc, func(m database.Mirror) bool { return isRepoSynced(m, p.Repository, p.RepoArch) })
+	if len(candidates) == 0 {
+		return conf.String("main.repourl") + p.Dir() + "/"
+	}
+
+	n := 1
+	for n < len(candidates) && candidates[n].match == candidates[0].match {
+		n++
+	}
+
+	return candidates[rand.Intn(n)].RepoURL(p.Repository, p.RepoArch)
+}
+
+// downloadURL returns the URL of the package on the download redirector.
+// By default, the /download/ route of the API is used,
+// unless main.downloadurl overrides it.
+func downloadURL(r *http.Request, p database.Package) string {
+	base := conf.String("main.downloadurl")
+	if base == "" {
+		base = apiURL(r) + "/download/"
+	}
+
+	return base + p.Dir() + "/" + p.Filename
+}
+
+// apiURL returns the base URL of the API as requested by the client.
+// The X-Forwarded-Proto and X-Forwarded-Host headers are used
+// if the request comes from a trusted proxy.
+func apiURL(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if ip := net.ParseIP(remote); ip != nil && isTrustedProxy(ip) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		if fhost := r.Header.Get("X-Forwarded-Host"); fhost != "" {
+			host = strings.TrimSpace(strings.Split(fhost, ",")[0])
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// packageMetalink returns the metalink document of a package.
+// Mirrors are prioritized by proximity with the client location.
+func packageMetalink(p database.Package, mirrors []database.Mirror, loc geoip.Location) *metalink.Metalink {
+	f := metalink.File{
+		Name: p.Filename,
+		Size: p.PackageSize,
+	}
+
+	if p.Sha256Sum != "" {
+		f.Hashes = append(f.Hashes, metalink.Hash{Type: "sha-256", Value: p.Sha256Sum})
+	}
+	if p.Md5Sum != "" {
+		f.Hashes = append(f.Hashes, metalink.Hash{Type: "md5", Value: p.Md5Sum})
+	}
+
+	candidates := rankMirrors(mirrors, loc, func(m database.Mirror) bool { return isRepoSynced(m, p.Repository, p.RepoArch) })
+	for _, c := range candidates {
+		f.URLs = append(f.URLs, metalink.URL{
+			Priority: c.match + 1,
+			Location: strings.ToLower(c.CountryCode),
+			Value:    c.RepoURL(p.Repository, p.RepoArch) + p.Filename,
+		})
+	}
+
+	if len(f.URLs) == 0 {
+		f.URLs = append(f.URLs, metalink.URL{
+			Priority: 1,
+			Value:    conf.String("main.repourl") + p.Dir() + "/" + p.Filename,
+		})
+	}
+
+	return metalink.New("pmanager").AddFile(f)
+}
+
+// mirrorURL returns the base URL of a mirror
+// (without the $repo variable and with a trailing slash).
+func mirrorURL(uri string) (string, error) {
+	uri = strings.TrimSpace(database.MirrorName(uri))
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Invalid mirror URL: %s", uri)
+	}
+
+	if !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+
+	return uri, nil
+}
@@ -1,18 +1,60 @@
 package serve
 
 import (
+	"net"
 	"pmanager/conf"
 	"pmanager/database"
+	"pmanager/log"
+	"pmanager/util/geoip"
 	"pmanager/util/mail"
+	"strings"
 )
 
 var (
 	port              string
 	defaultPagination int64
+	geo               *geoip.Reader
+	trustedProxies    []*net.IPNet
 )
 
+func parseNetworks(networks []string) (out []*net.IPNet) {
+	for _, n := range networks {
+		if !strings.Contains(n, "/") {
+			if ip := net.ParseIP(n); ip == nil {
+				log.Warnf("Invalid IP address: %s\n", n)
+				continue
+			} else if ip.To4() != nil {
+				n += "/32"
+			} else {
+				n += "/128"
+			}
+		}
+		if _, ipnet, err := net.ParseCIDR(n); err == nil {
+			out = append(out, ipnet)
+		} else {
+			log.Warnf("Invalid network: %s\n", n)
+		}
+	}
+
+	return
+}
+
 func init() {
 	database.Load(conf.String("database.uri"))
+	database.InitMirrorChecker(
+		conf.Int("mirror.workers"),
+		conf.Int("mirror.workers_per_host"),
+		conf.Int("mirror.connect_timeout"),
+		conf.Int("mirror.read_timeout"),
+		conf.Int("mirror.cert_warning_days"),
+	)
+	database.InitRepoUpdate(
+		conf.Int("repository.workers"),
+		conf.Int("repository.max_removal"),
+	)
+	database.InitMirrorRepos(conf.Slice("mirror.extra_repos"), conf.Slice("repository.architectures"))
+	database.InitMirrorApplications(conf.Int("mirror.probation_days"))
+	database.InitSignatureCheck(conf.String("repository.keyring"), conf.Bool("repository.verify_signatures"))
 	mail.InitSmtp(
 		conf.String("smtp.host"),
 		conf.String("smtp.port"),
@@ -22,4 +64,12 @@ func init() {
 	)
 	port = conf.String("api.port")
 	defaultPagination = conf.Int("api.pagination")
+	trustedProxies = parseNetworks(conf.Slice("mirror.trusted_proxies"))
+
+	if geoipDb := conf.String("mirror.geoip"); geoipDb != "" {
+		var err error
+		if geo, err = geoip.Open(geoipDb); err != nil {
+			log.Warnf("Failed to load the GeoIP database: %s\n", err)
+		}
+	}
 }
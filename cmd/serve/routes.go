@@ -1,6 +1,7 @@
 package serve
 
 import (
+	"fmt"
 	"html"
 	"net/http"
 	"net/mail"
@@ -8,14 +9,20 @@ import (
 	"pmanager/database"
 	"pmanager/log"
 	"pmanager/util/conv"
-	"strconv"
+	"pmanager/util/graph"
+	"pmanager/util/metalink"
+	"pmanager/util/pacman"
+	"pmanager/util/pgp"
+	"pmanager/util/spdx"
+	"sort"
 	"strings"
+	"time"
 )
 
 var routes = map[string]func(http.ResponseWriter, *http.Request){
 	"/flag/list": func(w http.ResponseWriter, r *http.Request) {
 		q := initPaginationQuery(r)
-		mf := getFilter(r, "search", "repo", "email", "from|d", "to|d")
+		mf := getFilter(r, "search", "repo", "arch", "email", "from|d", "to|d")
 		ms := getSort(r, "name", "repo", "date")
 
 		if mf.Exists("search") {
@@ -24,6 +31,9 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 		if mf.Exists("repo") {
 			q.AddFilter("repository", "=", mf.GetString("repo"))
 		}
+		if mf.Exists("arch") {
+			q.AddFilter("arch", "=", mf.GetString("arch"))
+		}
 		if mf.Exists("email") {
 			q.AddFilter("email", "LIKE", like(mf.GetString("email")))
 		}
@@ -69,6 +79,7 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 		f := database.Flag{
 			Name:       getString(r, "name"),
 			Version:    getString(r, "version"),
+			Arch:       getString(r, "arch"),
 			Repository: getString(r, "repo"),
 			Email:      email.Address,
 			Comment:    html.EscapeString(getString(r, "comment")),
@@ -78,27 +89,44 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 			AddFilter("name", "=", f.Name).
 			AddFilter("version", "=", f.Version).
 			AddFilter("repository", "=", f.Repository)
+		if f.Arch != "" {
+			q.AddFilter("repo_arch", "=", f.Arch)
+		}
 
 		ok := database.First(&p, q)
 		if ok = ok && p.FlagID == 0; ok {
+			f.Arch = p.RepoArch
 			if p.Repository != "build" {
 				ok = !database.First(
 					new(database.Package),
 					(new(database.Request)).
 						AddFilter("name", "=", p.Name).
-						AddFilter("repository", "=", "build"),
+						AddFilter("repository", "=", "build").
+						AddFilter("repo_arch", "=", p.RepoArch),
 				)
 			}
 		}
 
 		code := http.StatusOK
+		var flagged []string
 		if !ok {
 			code = http.StatusNotFound
 			f = database.Flag{}
 		} else {
-			p.Flag = f
-			if err := database.CreateFlag(&p); err == nil {
-				sendMail(p)
+			packages := []database.Package{p}
+			if getBool(r, "pkgbase") {
+				packages = packages[:0]
+				for _, sp := range database.FindPkgBase(p.Repository, p.RepoArch, p.PkgBase()) {
+					if sp.FlagID == 0 && sp.Version == p.Version {
+						packages = append(packages, sp)
+					}
+				}
+			}
+			if err := database.CreateFlags(packages, f); err == nil {
+				sendMail(packages...)
+				for _, sp := range packages {
+					flagged = append(flagged, sp.FullName())
+				}
 			} else {
 				log.Debugf("Failed to create flag: %s\n", err)
 				code = http.StatusInternalServerError
@@ -106,20 +134,15 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 		}
 
 		writeResponse(r, w, conv.Map{
-			"data": f,
+			"data":     f,
+			"packages": flagged,
 		}, code)
 	},
 	"/flag/delete": func(w http.ResponseWriter, r *http.Request) {
-		sids := strings.Split(getString(r, "ids"), ",")
-		ids := make([]uint, len(sids))
-
-		for i, sid := range sids {
-			if id, err := strconv.ParseUint(sid, 10, 64); err == nil {
-				ids[i] = uint(id)
-			} else {
-				writeResponse(r, w, err, http.StatusBadRequest)
-				return
-			}
+		ids, err := getIds(r, "ids")
+		if err != nil {
+			writeResponse(r, w, err, http.StatusBadRequest)
+			return
 		}
 
 		c := database.DeleteFlags(ids)
@@ -133,34 +156,463 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 		database.SearchAll(&countries, "Mirrors.Repos")
 		writeResponse(r, w, countries)
 	},
+	"/mirror/nearest": func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		loc, err := geo.Lookup(ip)
+		if err != nil {
+			log.Debugf("Failed to locate %s: %s\n", ip, err)
+		}
+
+		var mirrors []database.Mirror
+		database.Search(
+			&mirrors,
+			database.NewFilterRequest(database.NewFilter("online", "=", true)),
+			"Repos",
+		)
+
+		writeResponse(r, w, conv.Map{
+			"data":      nearestMirrors(mirrors, loc, getInt(r, "limit")),
+			"ip":        ip.String(),
+			"country":   loc.Country,
+			"continent": loc.Continent,
+		})
+	},
+	"/mirror/apply": func(w http.ResponseWriter, r *http.Request) {
+		email, err := mail.ParseAddress(getString(r, "email"))
+		if err != nil {
+			writeResponse(r, w, conv.Map{"data": nil, "error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		uri, err := mirrorURL(getString(r, "url"))
+		if err != nil {
+			writeResponse(r, w, conv.Map{"data": nil, "error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		country := strings.TrimSpace(getString(r, "country"))
+		if country == "" {
+			writeResponse(r, w, conv.Map{"data": nil, "error": "Missing country"}, http.StatusBadRequest)
+			return
+		}
+
+		exists := database.First(
+			new(database.Mirror),
+			database.NewFilterRequest(database.NewFilter("name", "=", uri)),
+		) || database.First(
+			new(database.MirrorApplication),
+			database.NewFilterRequest(
+				database.NewFilter("url", "=", uri),
+				database.NewFilter("status", "<>", database.ApplicationRejected),
+			),
+		)
+		if exists {
+			writeResponse(r, w, conv.Map{"data": nil, "error": "Mirror already registered"}, http.StatusConflict)
+			return
+		}
+
+		if !allowApplication(r) {
+			writeResponse(r, w, conv.Map{"data": nil, "error": "Too many applications, retry later"}, http.StatusTooManyRequests)
+			return
+		}
+
+		a := database.MirrorApplication{
+			URL:      uri,
+			Country:  html.EscapeString(country),
+			Operator: html.EscapeString(getString(r, "operator")),
+			Email:    email.Address,
+			Comment:  html.EscapeString(getString(r, "comment")),
+			Status:   database.ApplicationPending,
+		}
+		if err := database.CreateMirrorApplication(&a); err != nil {
+			log.Debugf("Failed to create mirror application: %s\n", err)
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusInternalServerError)
+			return
+		}
+
+		sendApplicationMail(a)
+		writeResponse(r, w, conv.Map{"data": a})
+	},
+	"/mirror/application/list": func(w http.ResponseWriter, r *http.Request) {
+		status := getString(r, "status")
+		if status == "" {
+			status = database.ApplicationPending
+		}
+
+		var applications []database.MirrorApplication
+		q := database.NewRequest(
+			[]database.Filter{database.NewFilter("status", "=", status)},
+			[]database.Sort{database.NewSort("created_at", false)},
+		)
+		if !database.Search(&applications, q) {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusInternalServerError)
+			return
+		}
+
+		data := make([]conv.Map, len(applications))
+		for i, a := range applications {
+			data[i] = conv.ToMap(a)
+			data[i]["Validated"] = a.IsValidated()
+		}
+
+		writeResponse(r, w, conv.Map{"data": data})
+	},
+	"/mirror/application/approve": func(w http.ResponseWriter, r *http.Request) {
+		ids, err := getIds(r, "ids")
+		if err != nil {
+			writeResponse(r, w, err, http.StatusBadRequest)
+			return
+		}
+
+		c, err := database.ApproveMirrorApplications(ids, conf.String("mirror.managed_mirrorlist"))
+		if err != nil {
+			log.Errorf("Failed to approve mirror applications: %s\n", err)
+			writeResponse(r, w, conv.Map{"applications_approved": c}, http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(r, w, conv.Map{"applications_approved": c})
+	},
+	"/mirror/application/reject": func(w http.ResponseWriter, r *http.Request) {
+		ids, err := getIds(r, "ids")
+		if err != nil {
+			writeResponse(r, w, err, http.StatusBadRequest)
+			return
+		}
+
+		c := database.RejectMirrorApplications(ids)
+		writeResponse(r, w, conv.Map{"applications_rejected": c})
+	},
+	"/stats/packagers": func(w http.ResponseWriter, r *http.Request) {
+		recent := int(getInt(r, "recent"))
+		if recent <= 0 {
+			recent = 5
+		}
+
+		stats, err := database.GetPackagerStats(recent)
+		if err != nil {
+			log.Debugf("Failed to compute the packager statistics: %s\n", err)
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusInternalServerError)
+			return
+		}
+		data := make([]conv.Map, len(stats))
+		for i, st := range stats {
+			builds := make([]conv.Map, len(st.LastBuilds))
+			for j, p := range st.LastBuilds {
+				builds[j] = conv.Map{
+					"FullName":  p.FullName(),
+					"RepoArch":  p.RepoArch,
+					"BuildDate": p.BuildDate,
+				}
+			}
+			data[i] = conv.Map{
+				"Packager":          st.Packager,
+				"Packages":          st.Packages,
+				"Repos":             st.Repos,
+				"Flagged":           st.Flagged,
+				"LastBuilds":        builds,
+				"ResolvedFlags":     st.ResolvedFlags,
+				"AverageResolution": st.AverageResolution.Round(time.Minute).String(),
+			}
+		}
+
+		writeResponse(r, w, conv.Map{"data": data})
+	},
+	"/group/list": func(w http.ResponseWriter, r *http.Request) {
+		groups := database.GetGroups(getString(r, "repo"), getString(r, "arch"))
+		data := make([]conv.Map, len(groups))
+		for i, g := range groups {
+			repos := make(conv.Map)
+			for name, rs := range g.Repos {
+				repos[name] = conv.Map{
+					"Packages":      rs.Packages,
+					"InstalledSize": conv.ToSize(rs.InstalledSize),
+				}
+			}
+			data[i] = conv.Map{
+				"Name":          g.Name,
+				"Packages":      g.Packages,
+				"InstalledSize": conv.ToSize(g.InstalledSize),
+				"Repos":         repos,
+			}
+		}
+
+		writeResponse(r, w, conv.Map{"data": data})
+	},
+	"/group/view": func(w http.ResponseWriter, r *http.Request) {
+		name := getString(r, "name")
+		packages := database.FindGroup(name, getString(r, "repo"), getString(r, "arch"))
+		if name == "" || len(packages) == 0 {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		var size int64
+		members := make([]conv.Map, len(packages))
+		for i, p := range packages {
+			size += p.InstalledSize
+			var flag conv.Map
+			if p.FlagID != 0 {
+				flag = conv.Map{
+					"Date":    p.Flag.CreatedAt,
+					"Comment": p.Flag.Comment,
+				}
+			}
+			members[i] = conv.Map{
+				"Repository":    p.Repository,
+				"RepoArch":      p.RepoArch,
+				"Name":          p.Name,
+				"Version":       p.Version,
+				"Description":   p.Description,
+				"InstalledSize": conv.ToSize(p.InstalledSize),
+				"Flagged":       p.FlagID != 0,
+				"Flag":          flag,
+				"FullName":      p.FullName(),
+			}
+		}
+
+		writeResponse(r, w, conv.Map{
+			"data": conv.Map{
+				"Name":          name,
+				"Packages":      members,
+				"InstalledSize": conv.ToSize(size),
+			},
+		})
+	},
+	"/license/list": func(w http.ResponseWriter, r *http.Request) {
+		licenses := database.GetLicenses(getString(r, "repo"), getString(r, "arch"))
+		data := make([]conv.Map, len(licenses))
+		for i, l := range licenses {
+			packages := make([]string, len(l.Packages))
+			for j, p := range l.Packages {
+				packages[j] = p.ArchRepoName()
+			}
+			data[i] = conv.Map{
+				"Name":     l.Name,
+				"Status":   l.Status,
+				"Count":    len(l.Packages),
+				"Packages": packages,
+			}
+		}
+
+		writeResponse(r, w, conv.Map{"data": data, "spdx_version": spdx.ListVersion})
+	},
+	"/report/licenses": func(w http.ResponseWriter, r *http.Request) {
+		issue := getString(r, "issue")
+		issues := database.GetLicenseIssues(getString(r, "repo"), getString(r, "arch"))
+
+		count := make(map[string]int)
+		data := []conv.Map{}
+		for _, li := range issues {
+			selected := issue == ""
+			for _, i := range li.Issues {
+				count[i]++
+				selected = selected || i == issue
+			}
+			if !selected {
+				continue
+			}
+
+			p := li.Package
+			data = append(data, conv.Map{
+				"FullName":    p.FullName(),
+				"RepoArch":    p.RepoArch,
+				"Licenses":    p.Licenses,
+				"Issues":      li.Issues,
+				"Identifiers": li.Identifiers,
+			})
+		}
+
+		writeResponse(r, w, conv.Map{
+			"data":         data,
+			"count":        count,
+			"spdx_version": spdx.ListVersion,
+		})
+	},
+	"/repo/pacman": func(w http.ResponseWriter, r *http.Request) {
+		cnf, err := pacman.Parse(conf.String("mirror.pacmanconf"))
+		if err != nil {
+			log.Debugf("Failed to read pacman configuration: %s\n", err)
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(r, w, conv.Map{
+			"data":          cnf.Repositories,
+			"architectures": cnf.Architectures,
+			"siglevel":      cnf.SigLevel,
+		})
+	},
 	"/update/mirror": func(w http.ResponseWriter, r *http.Request) {
-		data := database.UpdateMirrors(
+		res := database.UpdateMirrors(
 			conf.String("mirror.pacmanconf"),
 			conf.String("mirror.mirrorlist"),
+			conf.String("mirror.managed_mirrorlist"),
 			conf.String("mirror.main_mirror"),
+			conf.String("mirror.geoip"),
+			getBool(r, "dry_run"),
 		)
-		writeResponse(r, w, data)
+		writeUpdateResult(r, w, res)
 	},
 	"/update/repo": func(w http.ResponseWriter, r *http.Request) {
-		data := database.UpdatePackages(
+		res := database.UpdatePackages(
 			conf.String("repository.basedir"),
 			conf.String("repository.extension"),
 			conf.Slice("repository.include"),
 			conf.Slice("repository.exclude"),
+			conf.Slice("repository.architectures"),
+			getStrings(r, "name"),
+			getBool(r, "force"),
+			getBool(r, "dry_run"),
 		)
-		writeResponse(r, w, data)
+		writeUpdateResult(r, w, res)
+	},
+	"/update/signature": func(w http.ResponseWriter, r *http.Request) {
+		res := database.VerifySignatures(conf.String("repository.basedir"))
+		writeUpdateResult(r, w, res)
+	},
+	"/report/signatures": func(w http.ResponseWriter, r *http.Request) {
+		q := initPaginationQuery(r)
+		mf := getFilter(r, "status", "repo", "arch")
+
+		if mf.Exists("status") {
+			status := mf.GetString("status")
+			if status == "unchecked" {
+				status = ""
+			}
+			q.AddFilter("COALESCE(signature_status, '')", "=", status)
+		} else {
+			q.AddFilter("COALESCE(signature_status, '')", "<>", pgp.Valid)
+		}
+		if mf.Exists("repo") {
+			q.AddFilter("repository", "=", mf.GetString("repo"))
+		}
+		if mf.Exists("arch") {
+			q.AddFilter("repo_arch", "=", mf.GetString("arch"))
+		}
+		q.AddSort("repository", false).AddSort("name", false)
+
+		var packages []database.Package
+		pagination, ok := database.Paginate(&packages, q)
+		if !ok {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusInternalServerError)
+			return
+		}
+
+		data := make([]conv.Map, len(packages))
+		for i, p := range packages {
+			status := p.SignatureStatus
+			if status == "" {
+				status = "unchecked"
+			}
+			data[i] = conv.Map{
+				"FullName":          p.FullName(),
+				"RepoArch":          p.RepoArch,
+				"Filename":          p.Filename,
+				"Packager":          p.Packager,
+				"SignatureStatus":   status,
+				"SignerFingerprint": p.SignerFingerprint,
+			}
+		}
+
+		var states []database.RepoState
+		database.SearchAll(&states)
+		databases := make([]conv.Map, len(states))
+		for i, st := range states {
+			status := st.SignatureStatus
+			if status == "" {
+				status = "unchecked"
+			}
+			databases[i] = conv.Map{
+				"Repository":        st.Name,
+				"RepoArch":          st.Arch,
+				"SignatureStatus":   status,
+				"SignerFingerprint": st.SignerFingerprint,
+			}
+		}
+
+		writeResponse(r, w, conv.Map{
+			"data":      data,
+			"databases": databases,
+			"paginate":  pagination,
+		})
+	},
+	"/report/integrity": func(w http.ResponseWriter, r *http.Request) {
+		res := database.CheckIntegrity(conf.String("repository.basedir"), getStrings(r, "name"))
+		writeUpdateResult(r, w, res)
 	},
 	"/update/all": func(w http.ResponseWriter, r *http.Request) {
-		data := database.UpdateAll(
+		res := database.UpdateAll(
 			conf.String("mirror.pacmanconf"),
 			conf.String("mirror.mirrorlist"),
+			conf.String("mirror.managed_mirrorlist"),
 			conf.String("mirror.main_mirror"),
+			conf.String("mirror.geoip"),
 			conf.String("repository.basedir"),
 			conf.String("repository.extension"),
 			conf.Slice("repository.include"),
 			conf.Slice("repository.exclude"),
+			conf.Slice("repository.architectures"),
+			getBool(r, "force"),
+			getBool(r, "dry_run"),
+		)
+		writeUpdateResult(r, w, res)
+	},
+	"/pkgbase/view": func(w http.ResponseWriter, r *http.Request) {
+		name := getString(r, "name")
+		i := strings.Index(name, "/")
+		if i <= 0 {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		repo, base := name[:i], name[i+1:]
+		packages := database.FindPkgBase(repo, getString(r, "arch"), base)
+		if len(packages) == 0 {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		var (
+			list     = make([]conv.Map, len(packages))
+			archs    = make(map[string]bool)
+			flagged  bool
+			packager string
 		)
-		writeResponse(r, w, data)
+		for i, p := range packages {
+			list[i] = conv.Map{
+				"Name":        p.Name,
+				"Version":     p.Version,
+				"Arch":        p.Arch,
+				"RepoArch":    p.RepoArch,
+				"Description": p.Description,
+				"Flagged":     p.FlagID != 0,
+				"FullName":    p.FullName(),
+			}
+			archs[p.RepoArch] = true
+			flagged = flagged || p.FlagID != 0
+			if packager == "" {
+				packager = p.Packager
+			}
+		}
+
+		repoArchs := make([]string, 0, len(archs))
+		for a := range archs {
+			repoArchs = append(repoArchs, a)
+		}
+		sort.Strings(repoArchs)
+
+		writeResponse(r, w, conv.Map{
+			"data": conv.Map{
+				"Repository": repo,
+				"Name":       base,
+				"Version":    packages[0].Version,
+				"Packager":   packager,
+				"RepoArchs":  repoArchs,
+				"Flagged":    flagged,
+				"Packages":   list,
+			},
+		})
 	},
 	"/package/view": func(w http.ResponseWriter, r *http.Request) {
 		name := getString(r, "name")
@@ -170,13 +622,13 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 		}
 
 		var p database.Package
-		if !database.GetPackage(
-			&p,
-			database.NewFilterRequest(
-				database.NewFilter("repository || '/' || name || '-' || version", "=", name),
-			),
-			conf.String("repository.basedir"),
-		) {
+		q := database.NewFilterRequest(
+			database.NewFilter("repository || '/' || name || '-' || version", "=", name),
+		)
+		if arch := getString(r, "arch"); arch != "" {
+			q.AddFilter("repo_arch", "=", arch)
+		}
+		if !database.GetPackage(&p, q, conf.String("repository.basedir")) {
 			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
 			return
 		}
@@ -199,6 +651,10 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 			"Md5Sum":        p.Md5Sum,
 			"Sha256Sum":     p.Sha256Sum,
 			"Filename":      p.Filename,
+			"Base":          p.PkgBase(),
+			"Packager":      p.Packager,
+			"Signed":        p.PgpSig != "",
+			"RepoArch":      p.RepoArch,
 			"Flagged":       p.FlagID != 0,
 			"CompleteName":  p.VersionName(),
 			"FullName":      p.FullName(),
@@ -210,7 +666,7 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 
 		url := conv.Map{
 			"Upstream": p.URL,
-			"Download": conf.String("main.repourl") + p.Repository + "/" + p.Filename,
+			"Download": downloadURL(r, p),
 		}
 		if p.GitID != 0 {
 			g := p.Git
@@ -224,6 +680,123 @@ var routes = map[string]func(http.ResponseWriter, *http.Request){
 		data["URL"] = url
 		writeResponse(r, w, conv.Map{"data": data})
 	},
+	"/download/": func(w http.ResponseWriter, r *http.Request) {
+		elems := strings.Split(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+		l := len(elems)
+		if l < 2 || l > 3 || elems[0] == "" || elems[l-1] == "" {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		var p database.Package
+		filename := elems[l-1]
+		q := database.NewFilterRequest(
+			database.NewFilter("repository", "=", elems[0]),
+			database.NewFilter("filename", "=", strings.TrimSuffix(filename, ".sig")),
+		)
+		if l == 3 {
+			q.AddFilter("repo_arch", "=", elems[1])
+		}
+		if !database.First(&p, q) {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		loc, err := geo.Lookup(clientIP(r))
+		if err != nil {
+			log.Debugf("Failed to locate %s: %s\n", r.RemoteAddr, err)
+		}
+
+		var mirrors []database.Mirror
+		database.Search(
+			&mirrors,
+			database.NewFilterRequest(database.NewFilter("online", "=", true)),
+			"Repos",
+		)
+
+		url := downloadMirror(mirrors, loc, p) + filename
+		debugRequest(r, http.StatusFound)
+		http.Redirect(w, r, url, http.StatusFound)
+	},
+	"/package/metalink": func(w http.ResponseWriter, r *http.Request) {
+		repo, name, ok := strings.Cut(getString(r, "name"), "/")
+		if !ok || repo == "" || name == "" {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		var p database.Package
+		q := database.NewFilterRequest(
+			database.NewFilter("repository", "=", repo),
+			database.NewFilter("name", "=", name),
+		)
+		if !database.First(&p, q) {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		loc, err := geo.Lookup(clientIP(r))
+		if err != nil {
+			log.Debugf("Failed to locate %s: %s\n", r.RemoteAddr, err)
+		}
+
+		var mirrors []database.Mirror
+		database.Search(
+			&mirrors,
+			database.NewFilterRequest(database.NewFilter("online", "=", true)),
+			"Repos",
+		)
+
+		debugRequest(r, http.StatusOK)
+		w.Header().Add("Content-Type", metalink.MimeType)
+		w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", p.Filename, metalink.Extension))
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+		if err := packageMetalink(p, mirrors, loc).Write(w, log.Debug); err != nil {
+			log.Debugf("Response error: %s\n", err)
+		}
+	},
+	"/package/graph": func(w http.ResponseWriter, r *http.Request) {
+		repo, name, ok := strings.Cut(getString(r, "name"), "/")
+		if !ok || repo == "" || name == "" {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		depth := 1
+		if getString(r, "depth") != "" {
+			depth = int(getInt(r, "depth"))
+		}
+		kinds := getStrings(r, "kinds")
+		if len(kinds) == 0 {
+			kinds = []string{database.DependKind}
+		}
+		format := getString(r, "format")
+		if format == "" {
+			format = graph.Json
+		}
+		if !graph.IsFormat(format) || !database.IsDependKinds(kinds) {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusBadRequest)
+			return
+		}
+
+		g, ok := database.BuildGraph(repo, getString(r, "arch"), name, depth, kinds)
+		if !ok {
+			writeResponse(r, w, conv.Map{"data": nil}, http.StatusNotFound)
+			return
+		}
+
+		if format == graph.Json {
+			writeResponse(r, w, conv.Map{"data": g})
+			return
+		}
+
+		debugRequest(r, http.StatusOK)
+		w.Header().Add("Content-Type", graph.MimeType(format))
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+		if err := g.Write(w, format, log.Debug); err != nil {
+			log.Debugf("Response error: %s\n", err)
+		}
+	},
 	"/package/list": func(w http.ResponseWriter, r *http.Request) {
 		getPackages(w, r, "")
 	},
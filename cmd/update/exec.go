@@ -4,34 +4,85 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"pmanager/conf"
+	"pmanager/database"
 	"pmanager/log"
 	"pmanager/util/conv"
+	"strings"
 )
 
+// Exit codes of the update commands
+const (
+	exitFailure = 1 // nothing was updated
+	exitPartial = 2 // some repositories or mirrors failed
+)
+
+// Routes of the API which aren’t /update/<type>
+var apiRoutes = map[string]string{
+	"integrity": "/report/integrity",
+}
+
+func exit(status string) {
+	switch status {
+	case database.UpdateFailure:
+		os.Exit(exitFailure)
+	case database.UpdatePartial:
+		os.Exit(exitPartial)
+	}
+}
+
 func updateApi(t string) {
-	url := fmt.Sprintf("http://localhost:%s/update/%s", conf.String("api.port"), t)
-	data, err := http.Get(url)
+	query := make(url.Values)
+	if Force {
+		query.Set("force", "1")
+	}
+	if DryRun {
+		query.Set("dry_run", "1")
+	}
+	if (t == "repo" || t == "integrity") && len(Repos) > 0 {
+		query.Set("name", strings.Join(Repos, ","))
+	}
+
+	route, ok := apiRoutes[t]
+	if !ok {
+		route = "/update/" + t
+	}
+
+	uri := fmt.Sprintf("http://localhost:%s%s", conf.String("api.port"), route)
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	data, err := http.Get(uri)
 
 	if err != nil {
 		log.Fatalln(err)
 	}
 
 	if data.Body != nil {
-		defer data.Body.Close()
 		log.Copy(data.Body)
+		data.Body.Close()
+	}
+
+	switch data.StatusCode {
+	case http.StatusOK:
+	case http.StatusMultiStatus:
+		exit(database.UpdatePartial)
+	default:
+		exit(database.UpdateFailure)
 	}
 }
 
 func updateServer(t string) {
-	data := upd[t]()
+	res := upd[t]()
 
-	if data != nil {
-		var buf bytes.Buffer
-		if conv.WriteJson(&buf, data, log.Debug) != nil {
-			log.Copy(&buf)
-		}
+	var buf bytes.Buffer
+	if conv.WriteJson(&buf, res.Map(), log.Debug) == nil {
+		log.Copy(&buf)
 	}
+
+	exit(res.Status())
 }
 
 func update(t string) {
@@ -53,3 +104,11 @@ func Repositories() {
 func All() {
 	update("all")
 }
+
+func Signatures() {
+	update("signature")
+}
+
+func Integrity() {
+	update("integrity")
+}
@@ -7,32 +7,62 @@ import (
 )
 
 var (
+	// Force forces the rescan of all the repositories,
+	// even if their database didn’t change.
+	Force bool
+
+	// DryRun shows the changes of the update
+	// without writing them in the database.
+	DryRun bool
+
+	// Repos are the repositories to update or to verify.
+	// If empty, all the included repositories are updated.
+	Repos []string
+
 	serverOpen bool
-	upd        = map[string]func() map[string]int{
-		"mirror": func() map[string]int {
+	upd        = map[string]func() *database.UpdateResult{
+		"mirror": func() *database.UpdateResult {
 			return database.UpdateMirrors(
 				conf.String("mirror.pacmanconf"),
 				conf.String("mirror.mirrorlist"),
+				conf.String("mirror.managed_mirrorlist"),
 				conf.String("mirror.main_mirror"),
+				conf.String("mirror.geoip"),
+				DryRun,
 			)
 		},
-		"repo": func() map[string]int {
+		"repo": func() *database.UpdateResult {
 			return database.UpdatePackages(
 				conf.String("repository.basedir"),
 				conf.String("repository.extension"),
 				conf.Slice("repository.include"),
 				conf.Slice("repository.exclude"),
+				conf.Slice("repository.architectures"),
+				Repos,
+				Force,
+				DryRun,
 			)
 		},
-		"all": func() map[string]int {
+		"signature": func() *database.UpdateResult {
+			return database.VerifySignatures(conf.String("repository.basedir"))
+		},
+		"integrity": func() *database.UpdateResult {
+			return database.CheckIntegrity(conf.String("repository.basedir"), Repos)
+		},
+		"all": func() *database.UpdateResult {
 			return database.UpdateAll(
 				conf.String("mirror.pacmanconf"),
 				conf.String("mirror.mirrorlist"),
+				conf.String("mirror.managed_mirrorlist"),
 				conf.String("mirror.main_mirror"),
+				conf.String("mirror.geoip"),
 				conf.String("repository.basedir"),
 				conf.String("repository.extension"),
 				conf.Slice("repository.include"),
 				conf.Slice("repository.exclude"),
+				conf.Slice("repository.architectures"),
+				Force,
+				DryRun,
 			)
 		},
 	}
@@ -42,5 +72,19 @@ func init() {
 	port := conf.String("api.port")
 	if serverOpen = resource.IsPortOpen("localhost", port); !serverOpen {
 		database.Load(conf.String("database.uri"))
+		database.InitMirrorChecker(
+			conf.Int("mirror.workers"),
+			conf.Int("mirror.workers_per_host"),
+			conf.Int("mirror.connect_timeout"),
+			conf.Int("mirror.read_timeout"),
+			conf.Int("mirror.cert_warning_days"),
+		)
+		database.InitRepoUpdate(
+			conf.Int("repository.workers"),
+			conf.Int("repository.max_removal"),
+		)
+		database.InitMirrorRepos(conf.Slice("mirror.extra_repos"), conf.Slice("repository.architectures"))
+		database.InitMirrorApplications(conf.Int("mirror.probation_days"))
+		database.InitSignatureCheck(conf.String("repository.keyring"), conf.Bool("repository.verify_signatures"))
 	}
 }
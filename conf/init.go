@@ -18,10 +18,11 @@ func loadDefaultConf() {
 }
 
 func loadCustomConf(cnfPath string) (c *configuration, err error) {
-	var f io.Reader
+	var f io.ReadCloser
 	if f, err = resource.Open(cnfPath); err != nil {
 		log.Errorf("Failed to read the configuration file: %s\n", err)
 	} else {
+		defer f.Close()
 		c = newConfiguration(f)
 	}
 	return
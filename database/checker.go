@@ -0,0 +1,248 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// checker is an HTTP client to check the mirrors.
+// It limits the number of concurrent checks (globally and per host)
+// and applies timeouts to each request, so a dead mirror
+// cannot stall the whole update.
+type checker struct {
+	sync.Mutex
+	client      *http.Client
+	client4     *http.Client
+	client6     *http.Client
+	noRedirect  *http.Client
+	workers     int
+	perHost     int
+	certWarning time.Duration
+	hosts       map[string]chan bool
+}
+
+var mirrorChecker = newChecker(10, 2, 10, 30, 14)
+
+// Duration of the probation of the mirror applications
+var applicationProbation = 7 * 24 * time.Hour
+
+// newClient returns an HTTP client.
+// If network is set (tcp4 or tcp6), the connections
+// are forced on this network.
+func newClient(network string, perHost int64, ct, rt time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: ct,
+	}
+	dial := dialer.DialContext
+	if network != "" {
+		dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dial,
+		TLSHandshakeTimeout:   ct,
+		ResponseHeaderTimeout: rt,
+		MaxConnsPerHost:       int(perHost),
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   ct + rt,
+	}
+}
+
+func newChecker(workers, perHost, connectTimeout, readTimeout, certWarningDays int64) *checker {
+	if workers <= 0 {
+		workers = 1
+	}
+	if perHost <= 0 {
+		perHost = 1
+	}
+
+	ct, rt := time.Duration(connectTimeout)*time.Second, time.Duration(readTimeout)*time.Second
+	noRedirect := newClient("", perHost, ct, rt)
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &checker{
+		client:      newClient("", perHost, ct, rt),
+		client4:     newClient("tcp4", perHost, ct, rt),
+		client6:     newClient("tcp6", perHost, ct, rt),
+		noRedirect:  noRedirect,
+		workers:     int(workers),
+		perHost:     int(perHost),
+		certWarning: time.Duration(certWarningDays) * 24 * time.Hour,
+		hosts:       make(map[string]chan bool),
+	}
+}
+
+// InitMirrorChecker configures the checks of the mirrors.
+// - workers : maximum number of concurrent checks
+// - perHost : maximum number of concurrent checks on a same host
+// - connectTimeout : timeout (in seconds) to connect to a mirror
+// - readTimeout : timeout (in seconds) to read the response of a mirror
+// - certWarningDays : number of days before the expiration of a certificate to warn about it
+func InitMirrorChecker(workers, perHost, connectTimeout, readTimeout, certWarningDays int64) {
+	mirrorChecker = newChecker(workers, perHost, connectTimeout, readTimeout, certWarningDays)
+}
+
+// InitMirrorApplications configures the validation of the mirror applications.
+// - probationDays : number of days an application is checked before it can be approved
+func InitMirrorApplications(probationDays int64) {
+	applicationProbation = time.Duration(probationDays) * 24 * time.Hour
+}
+
+func (c *checker) acquire(uri string) (release func()) {
+	host := uri
+	if u, err := url.Parse(uri); err == nil {
+		host = u.Host
+	}
+
+	c.Lock()
+	sem, ok := c.hosts[host]
+	if !ok {
+		sem = make(chan bool, c.perHost)
+		c.hosts[host] = sem
+	}
+	c.Unlock()
+
+	sem <- true
+
+	return func() { <-sem }
+}
+
+func (c *checker) do(method, uri string) (resp *http.Response, err error) {
+	return c.doWith(c.client, method, uri)
+}
+
+func (c *checker) doWith(client *http.Client, method, uri string) (resp *http.Response, err error) {
+	var req *http.Request
+	if req, err = http.NewRequest(method, uri, nil); err != nil {
+		return
+	}
+
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("[%d] %s", resp.StatusCode, resp.Status)
+	}
+
+	return
+}
+
+// isUnreachable returns true if the error is a failure
+// to connect to the host (DNS resolution, refused connection…)
+// or a timeout.
+func isUnreachable(err error) bool {
+	var (
+		opErr  *net.OpError
+		netErr net.Error
+	)
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// head checks that the uri is available.
+func (c *checker) head(uri string) error {
+	return c.headWith(c.client, uri)
+}
+
+// headWith checks that the uri is available using the given client.
+func (c *checker) headWith(client *http.Client, uri string) error {
+	defer c.acquire(uri)()
+
+	resp, err := c.doWith(client, http.MethodHead, uri)
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	return err
+}
+
+// certificate checks that the uri is available over HTTPS
+// with a valid certificate and returns the expiration date
+// of the certificate.
+func (c *checker) certificate(uri string) (expiry time.Time, err error) {
+	defer c.acquire(uri)()
+
+	var resp *http.Response
+	if resp, err = c.do(http.MethodHead, uri); err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		err = errors.New("No certificate found")
+		return
+	}
+
+	return resp.TLS.PeerCertificates[0].NotAfter, nil
+}
+
+// redirectsToHttps checks if the uri (over HTTP)
+// is redirected to HTTPS.
+func (c *checker) redirectsToHttps(uri string) (bool, error) {
+	defer c.acquire(uri)()
+
+	req, err := http.NewRequest(http.MethodHead, uri, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.noRedirect.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return false, nil
+	}
+
+	location, err := resp.Location()
+
+	return err == nil && location.Scheme == "https", nil
+}
+
+// md5 returns the md5 sum of the content of the uri.
+func (c *checker) md5(uri string) (sum string, err error) {
+	defer c.acquire(uri)()
+
+	var resp *http.Response
+	if resp, err = c.do(http.MethodGet, uri); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	h := md5.New()
+	if _, err = io.Copy(h, resp.Body); err == nil {
+		sum = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	return
+}
+
+// run executes the tasks with the pool of workers
+// of the checker and waits for them to finish.
+func (c *checker) run(tasks []func()) {
+	runTasks(c.workers, tasks)
+}
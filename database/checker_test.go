@@ -0,0 +1,179 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestChecker returns a checker which trusts the certificate
+// of the given TLS test server.
+func newTestChecker(t *testing.T, srv *httptest.Server) *checker {
+	t.Helper()
+
+	c := newChecker(2, 2, 5, 5, 14)
+	if srv != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(srv.Certificate())
+		for _, client := range []*http.Client{c.client, c.client4, c.client6, c.noRedirect} {
+			client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+		}
+	}
+
+	return c
+}
+
+func TestCheckerCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := newTestChecker(t, srv)
+	expiry, err := c.certificate(srv.URL)
+	if err != nil {
+		t.Fatalf("certificate(%s): %s", srv.URL, err)
+	}
+	if want := srv.Certificate().NotAfter; !expiry.Equal(want) {
+		t.Errorf("certificate(%s) = %s, want %s", srv.URL, expiry, want)
+	}
+
+	// The certificate of the test server is not trusted by the default checker.
+	if _, err := newTestChecker(t, nil).certificate(srv.URL); err == nil {
+		t.Errorf("certificate(%s) with an untrusted certificate: no error", srv.URL)
+	}
+}
+
+func TestCertificateExpiresSoon(t *testing.T) {
+	old := mirrorChecker
+	defer func() { mirrorChecker = old }()
+	mirrorChecker = newChecker(1, 1, 1, 1, 14)
+
+	tests := []struct {
+		name   string
+		mirror Mirror
+		want   bool
+	}{
+		{"expired", Mirror{Https: true, CertificateExpiry: time.Now().Add(-time.Hour)}, true},
+		{"expires in 2 days", Mirror{Https: true, CertificateExpiry: time.Now().Add(48 * time.Hour)}, true},
+		{"expires in 30 days", Mirror{Https: true, CertificateExpiry: time.Now().Add(30 * 24 * time.Hour)}, false},
+		{"no https", Mirror{CertificateExpiry: time.Now()}, false},
+	}
+
+	for _, tt := range tests {
+		if got := certificateExpiresSoon(tt.mirror); got != tt.want {
+			t.Errorf("%s: certificateExpiresSoon() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckerRedirectsToHttps(t *testing.T) {
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, tlsSrv.URL+r.URL.Path, http.StatusMovedPermanently)
+	}))
+	defer redirect.Close()
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+
+	toHttp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/other", http.StatusFound)
+	}))
+	defer toHttp.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    bool
+		wantErr bool
+	}{
+		{"redirect to https", redirect.URL + "/repo/", true, false},
+		{"no redirect", plain.URL + "/repo/", false, false},
+		{"redirect to http", toHttp.URL + "/repo/", false, false},
+		{"server down", closed.URL + "/repo/", false, true},
+	}
+
+	c := newTestChecker(t, tlsSrv)
+	for _, tt := range tests {
+		got, err := c.redirectsToHttps(tt.uri)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: redirectsToHttps() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: redirectsToHttps() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMirrorCapabilities(t *testing.T) {
+	old := mirrorChecker
+	defer func() { mirrorChecker = old }()
+	mirrorChecker = newTestChecker(t, nil)
+
+	var requests int32
+	handler := func(status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(status)
+		}
+	}
+
+	closed := httptest.NewServer(handler(http.StatusOK))
+	closed.Close()
+	notFound := httptest.NewServer(handler(http.StatusNotFound))
+	defer notFound.Close()
+	online := httptest.NewServer(handler(http.StatusOK))
+	defer online.Close()
+
+	tests := []struct {
+		name         string
+		uri          string
+		online       bool
+		minRequests  int32
+		maxRequests  int32
+		wantFailures []string
+	}{
+		// The host cannot be reached: no capability check.
+		{"unreachable", closed.URL + "/", false, 0, 0, nil},
+		// The mirror answers but is offline and has no HTTPS:
+		// the redirection and the IP versions aren’t checked.
+		{"offline", notFound.URL + "/", false, 1, 1, []string{"https: "}},
+		{"online", online.URL + "/", true, 3, 4, []string{"https: "}},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&requests, 0)
+		mirror := Mirror{Name: tt.uri}
+		checkMirrorIsOnline(&mirror, nil)
+
+		if mirror.Online != tt.online {
+			t.Errorf("%s: Online = %v, want %v", tt.name, mirror.Online, tt.online)
+		}
+		if n := atomic.LoadInt32(&requests); n < tt.minRequests || n > tt.maxRequests {
+			t.Errorf("%s: %d requests, want between %d and %d", tt.name, n, tt.minRequests, tt.maxRequests)
+		}
+		if tt.wantFailures == nil && mirror.CapabilityError != "" {
+			t.Errorf("%s: unexpected capability error %q", tt.name, mirror.CapabilityError)
+		}
+		for _, f := range tt.wantFailures {
+			if !strings.Contains(mirror.CapabilityError, f) {
+				t.Errorf("%s: capability error %q does not contain %q", tt.name, mirror.CapabilityError, f)
+			}
+		}
+		if mirror.Https || mirror.RedirectsToHttps {
+			t.Errorf("%s: unexpected HTTPS capabilities %+v", tt.name, mirror)
+		}
+		if mirror.IPv4 != tt.online {
+			t.Errorf("%s: IPv4 = %v, want %v", tt.name, mirror.IPv4, tt.online)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"pmanager/log"
+	"pmanager/util/resource"
+)
+
+// OrphanFile is a package file (or the signature of a package)
+// of a repository directory which isn’t referenced by the database.
+type OrphanFile struct {
+	Repository string
+	Arch       string
+	Path       string // relative to the base directory
+	Size       int64
+	ModTime    time.Time
+}
+
+func isPackageFile(name string) bool {
+	return strings.Contains(name, ".pkg.tar.")
+}
+
+// readRepoFilenames returns the package files listed
+// in the database file of the repository.
+func readRepoFilenames(base, extension string, rd repoDir) (filenames []string, err error) {
+	var a *resource.Archive
+	if a, err = resource.OpenArchive(getRepoFilePath(base, rd, extension)); err != nil {
+		return
+	}
+	defer a.Close()
+
+	for {
+		hdr, err := a.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hdr.FileInfo().IsDir() || path.Base(hdr.Name) != "desc" {
+			continue
+		}
+
+		sc := bufio.NewScanner(a)
+		sc.Buffer(nil, maxLineSize)
+		p, err := scanDesc(sc, rd, path.Base(path.Dir(hdr.Name)))
+		if err == nil && p.Filename == "" {
+			err = errors.New("Missing %FILENAME% section")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", hdr.Name, err)
+		}
+		filenames = append(filenames, p.Filename)
+	}
+
+	return
+}
+
+// FindOrphanFiles searches the package files of the included repositories
+// which are referenced neither by the database files of the repositories
+// nor by the packages table (which may be older than the database files).
+// The directories whose database file can't be read are skipped
+// and returned, as well as the directories with no referenced package
+// (the repository has probably never been updated).
+func FindOrphanFiles(base, extension string, includes, excludes, archs []string) (files []OrphanFile, skipped []string, err error) {
+	var packages []Package
+
+	dbsingleton.Lock()
+	err = dbsingleton.
+		Model(&Package{}).
+		Select("repository, repo_path, filename").
+		Find(&packages).
+		Error
+	dbsingleton.Unlock()
+	if err != nil {
+		return
+	}
+
+	referenced := make(map[string]bool)
+	dirs := make(map[string]bool)
+	reference := func(dir, filename string) {
+		fp := path.Join(dir, filename)
+		referenced[fp], referenced[fp+".sig"] = true, true
+		dirs[dir] = true
+	}
+	for _, p := range packages {
+		reference(p.Dir(), p.Filename)
+	}
+
+	repos, err := getRepoDirs(base, getIncludes(includes, excludes), archs)
+	if err != nil {
+		return
+	}
+
+	for _, rd := range repos {
+		filenames, err := readRepoFilenames(base, extension, rd)
+		if err != nil {
+			log.Errorf("Failed to read the database of %s, skipped: %s\n", rd.path, err)
+			skipped = append(skipped, rd.String())
+			continue
+		}
+		for _, fn := range filenames {
+			reference(rd.path, fn)
+		}
+
+		if !dirs[rd.path] {
+			log.Warnf("No package referenced in %s, skipped\n", rd.path)
+			skipped = append(skipped, rd.String())
+			continue
+		}
+
+		entries, err := os.ReadDir(path.Join(base, rd.path))
+		if err != nil {
+			log.Errorf("Failed to read %s: %s\n", rd.path, err)
+			skipped = append(skipped, rd.String())
+			continue
+		}
+
+		for _, e := range entries {
+			fp := path.Join(rd.path, e.Name())
+			if !e.Type().IsRegular() || !isPackageFile(e.Name()) || referenced[fp] {
+				continue
+			}
+
+			fi, err := e.Info()
+			if err != nil {
+				continue
+			}
+
+			files = append(files, OrphanFile{
+				Repository: rd.name,
+				Arch:       rd.arch,
+				Path:       fp,
+				Size:       fi.Size(),
+				ModTime:    fi.ModTime(),
+			})
+		}
+	}
+
+	return files, skipped, nil
+}
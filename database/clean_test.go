@@ -0,0 +1,56 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func touchFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(n), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindOrphanFiles(t *testing.T) {
+	base := t.TempDir()
+	loadMemoryDb(t)
+
+	// The packages table is empty: the files must be
+	// referenced by the database file of the repository.
+	writeTestRepo(t, base, "core", 2, 1)
+	touchFiles(t, filepath.Join(base, "core"),
+		"pkg00000-1.0-1-x86_64.pkg.tar.zst",
+		"pkg00000-1.0-1-x86_64.pkg.tar.zst.sig",
+		"pkg00001-1.0-1-x86_64.pkg.tar.zst",
+		"pkg00001-0.9-1-x86_64.pkg.tar.zst",
+		"pkg00001-0.9-1-x86_64.pkg.tar.zst.sig",
+	)
+
+	// The database file of main is broken: main is skipped.
+	writeTestRepo(t, base, "main", 2, 1, 1)
+	touchFiles(t, filepath.Join(base, "main"), "pkg00002-1.0-1-x86_64.pkg.tar.zst")
+
+	files, skipped, err := FindOrphanFiles(base, "files.tar.gz", []string{"core", "main"}, nil, []string{"x86_64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path)
+	}
+	sort.Strings(got)
+	want := []string{"core/pkg00001-0.9-1-x86_64.pkg.tar.zst", "core/pkg00001-0.9-1-x86_64.pkg.tar.zst.sig"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got orphan files %v, want %v", got, want)
+	}
+	if len(skipped) != 1 || skipped[0] != "main/x86_64" {
+		t.Errorf("got skipped repositories %v, want [main/x86_64]", skipped)
+	}
+}
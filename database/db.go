@@ -94,6 +94,8 @@ type Filter struct {
 // - field : name of the field to apply the filter
 // - operation : comparison operator between the field and the value
 // - value : value to compare with the field
+// If operation is empty, field is the whole condition,
+// with a ? placeholder for the value.
 func NewFilter(field, operation string, value any) Filter {
 	return Filter{
 		field:     field,
@@ -103,6 +105,10 @@ func NewFilter(field, operation string, value any) Filter {
 }
 
 func (f Filter) String() string {
+	if f.operation == "" {
+		return f.field
+	}
+
 	return fmt.Sprintf("%s %s ?", f.field, f.operation)
 }
 
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"sort"
+
+	"pmanager/util/graph"
+	"pmanager/util/pacman"
+)
+
+// Kinds of dependencies
+const (
+	DependKind     = "depends"
+	MakeDependKind = "makedepends"
+	OptDependKind  = "optdepends"
+)
+
+// IsDependKinds returns true if all the kinds of dependencies are known.
+func IsDependKinds(kinds []string) bool {
+	for _, k := range kinds {
+		switch k {
+		case DependKind, MakeDependKind, OptDependKind:
+		default:
+			return false
+		}
+	}
+
+	return len(kinds) > 0
+}
+
+type provision struct {
+	p       *Package
+	version string
+}
+
+// dependIndex resolves the dependencies
+// among the packages of an architecture.
+type dependIndex struct {
+	byName   map[string][]*Package
+	provides map[string][]provision
+}
+
+func newDependIndex(packages []Package) *dependIndex {
+	idx := &dependIndex{
+		byName:   make(map[string][]*Package),
+		provides: make(map[string][]provision),
+	}
+
+	for i := range packages {
+		p := &packages[i]
+		idx.byName[p.Name] = append(idx.byName[p.Name], p)
+		for _, pr := range p.Provides {
+			d := pacman.ParseDepend(pr)
+			idx.provides[d.Name] = append(idx.provides[d.Name], provision{p, d.Version})
+		}
+	}
+
+	return idx
+}
+
+// resolve returns the package which satisfies the dependency,
+// or nil if the dependency is unresolved.
+// The packages with the same name are preferred to the providers,
+// then the packages of the repository of the dependent package.
+// An unversioned provision doesn’t satisfy a versioned dependency.
+func (idx *dependIndex) resolve(d pacman.Depend, from *Package) *Package {
+	var candidates []*Package
+	for _, p := range idx.byName[d.Name] {
+		if d.SatisfiedBy(p.Version) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, pr := range idx.provides[d.Name] {
+			if d.SatisfiedBy(pr.version) {
+				candidates = append(candidates, pr.p)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ri, rj := candidates[i].Repository == from.Repository, candidates[j].Repository == from.Repository
+		if ri != rj {
+			return ri
+		}
+		return candidates[i].Repository < candidates[j].Repository
+	})
+
+	return candidates[0]
+}
+
+func dependsOf(p *Package, kind string) SqlSlice {
+	switch kind {
+	case DependKind:
+		return p.Depends
+	case MakeDependKind:
+		return p.MakeDepends
+	case OptDependKind:
+		return p.OptDepends
+	}
+
+	return nil
+}
+
+func packageNode(p *Package) graph.Node {
+	return graph.Node{
+		ID:    p.ArchRepoName(),
+		Label: p.VersionName(),
+		Attrs: map[string]string{
+			"name":       p.Name,
+			"version":    p.Version,
+			"repository": p.Repository,
+			"arch":       p.RepoArch,
+		},
+	}
+}
+
+// BuildGraph builds the dependency graph of the package.
+// The dependencies are resolved among the packages of the same architecture,
+// using the provisions and the version constraints.
+// - depth : maximal depth of the graph (negative for no limit)
+// - kinds : kinds of dependencies to follow (depends, makedepends and/or optdepends)
+func BuildGraph(repo, arch, name string, depth int, kinds []string) (*graph.Graph, bool) {
+	var root Package
+
+	q := NewFilterRequest(
+		NewFilter("repository", "=", repo),
+		NewFilter("name", "=", name),
+	)
+	if arch != "" {
+		q.AddFilter("repo_arch", "=", arch)
+	}
+	if !First(&root, q) {
+		return nil, false
+	}
+
+	var packages []Package
+	dbsingleton.Lock()
+	dbsingleton.
+		Model(&Package{}).
+		Select("id, name, version, repository, repo_arch, depends, make_depends, opt_depends, provides").
+		Where("repo_arch = ?", root.RepoArch).
+		Find(&packages)
+	dbsingleton.Unlock()
+
+	rootP := &root
+	for i := range packages {
+		if packages[i].ID == root.ID {
+			rootP = &packages[i]
+		}
+	}
+
+	var (
+		idx   = newDependIndex(packages)
+		g     = graph.New(rootP.ArchRepoName())
+		level = []*Package{rootP}
+	)
+	g.AddNode(packageNode(rootP))
+
+	for d := 0; len(level) > 0 && (depth < 0 || d < depth); d++ {
+		var next []*Package
+		for _, p := range level {
+			for _, kind := range kinds {
+				for _, dep := range dependsOf(p, kind) {
+					dp := pacman.ParseDepend(dep)
+					e := graph.Edge{
+						From:  p.ArchRepoName(),
+						Kind:  kind,
+						Label: dp.Constraint(),
+					}
+
+					target := idx.resolve(dp, p)
+					if target == nil {
+						e.To = "missing/" + dp.String()
+						g.AddNode(graph.Node{ID: e.To, Label: dp.String(), Missing: true})
+						g.AddEdge(e)
+						continue
+					}
+
+					e.To = target.ArchRepoName()
+					if target.Name != dp.Name {
+						e.Label = dp.String() + " (provided)"
+					}
+					if !g.HasNode(e.To) {
+						g.AddNode(packageNode(target))
+						next = append(next, target)
+					}
+					g.AddEdge(e)
+				}
+			}
+		}
+		level = next
+	}
+
+	return g, true
+}
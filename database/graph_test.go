@@ -0,0 +1,167 @@
+package database
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"pmanager/util/graph"
+	"pmanager/util/pacman"
+)
+
+func graphPackage(repo, name, version string, depends ...string) Package {
+	return Package{
+		Repository: repo,
+		Name:       name,
+		Version:    version,
+		Arch:       "x86_64",
+		RepoArch:   "x86_64",
+		RepoPath:   repo,
+		Filename:   name + "-" + version + "-x86_64.pkg.tar.zst",
+		Depends:    depends,
+	}
+}
+
+func TestResolve(t *testing.T) {
+	packages := []Package{
+		graphPackage("core", "sh", "1.0-1"),
+		graphPackage("core", "bash", "5.2-1"),
+		graphPackage("core", "libfoo", "1.0-1"),
+		graphPackage("main", "libfoo", "1.1-1"),
+		graphPackage("core", "libbar-git", "2.0-1"),
+		graphPackage("core", "libbaz-git", "2.0-1"),
+	}
+	packages[1].Provides = SqlSlice{"sh"}
+	packages[4].Provides = SqlSlice{"libbar"}
+	packages[5].Provides = SqlSlice{"libbaz=2.0"}
+
+	var (
+		idx       = newDependIndex(packages)
+		fromCore  = &Package{Repository: "core"}
+		fromMain  = &Package{Repository: "main"}
+		fromOther = &Package{Repository: "other"}
+	)
+	tests := []struct {
+		name   string
+		depend string
+		from   *Package
+		want   *Package
+	}{
+		{"same name beats provider", "sh", fromCore, &packages[0]},
+		{"provider", "libbar", fromCore, &packages[4]},
+		{"unversioned provision", "libbar>=1", fromCore, nil},
+		{"versioned provision", "libbaz>=1", fromCore, &packages[5]},
+		{"versioned provision too old", "libbaz>=3", fromCore, nil},
+		{"own repository", "libfoo", fromMain, &packages[3]},
+		{"own repository first", "libfoo", fromCore, &packages[2]},
+		{"other repository", "libfoo", fromOther, &packages[2]},
+		{"constraint", "libfoo>1.0", fromCore, &packages[3]},
+		{"missing", "libqux", fromCore, nil},
+	}
+
+	for _, tt := range tests {
+		if got := idx.resolve(pacman.ParseDepend(tt.depend), tt.from); got != tt.want {
+			t.Errorf("%s: resolve(%s) = %+v, want %+v", tt.name, tt.depend, got, tt.want)
+		}
+	}
+}
+
+func nodeIDs(g *graph.Graph) []string {
+	ids := make([]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[i] = n.ID
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func edgeIDs(g *graph.Graph) []string {
+	ids := make([]string, len(g.Edges))
+	for i, e := range g.Edges {
+		ids[i] = e.From + " -> " + e.To
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestBuildGraph(t *testing.T) {
+	loadMemoryDb(t)
+
+	app := graphPackage("core", "app", "1.0-1", "lib1", "lib2>=2", "nowhere")
+	app.MakeDepends = SqlSlice{"make"}
+	saveTestPackages(t, []Package{
+		app,
+		graphPackage("core", "lib1", "1.0-1", "lib3"),
+		graphPackage("core", "lib2", "1.0-1"),
+		// lib3 depends on app: a cycle.
+		graphPackage("core", "lib3", "1.0-1", "app"),
+		graphPackage("core", "make", "4.4-1"),
+	})
+
+	tests := []struct {
+		name  string
+		depth int
+		kinds []string
+		nodes []string
+		edges []string
+	}{
+		{"depth 0", 0, []string{DependKind}, []string{"core/x86_64/app"}, []string{}},
+		{
+			"depth 1",
+			1,
+			[]string{DependKind},
+			[]string{"core/x86_64/app", "core/x86_64/lib1", "missing/lib2>=2", "missing/nowhere"},
+			[]string{"core/x86_64/app -> core/x86_64/lib1", "core/x86_64/app -> missing/lib2>=2", "core/x86_64/app -> missing/nowhere"},
+		},
+		{
+			"no limit",
+			-1,
+			[]string{DependKind},
+			[]string{"core/x86_64/app", "core/x86_64/lib1", "core/x86_64/lib3", "missing/lib2>=2", "missing/nowhere"},
+			[]string{
+				"core/x86_64/app -> core/x86_64/lib1",
+				"core/x86_64/app -> missing/lib2>=2",
+				"core/x86_64/app -> missing/nowhere",
+				"core/x86_64/lib1 -> core/x86_64/lib3",
+				"core/x86_64/lib3 -> core/x86_64/app",
+			},
+		},
+		{
+			"make dependencies",
+			1,
+			[]string{MakeDependKind},
+			[]string{"core/x86_64/app", "core/x86_64/make"},
+			[]string{"core/x86_64/app -> core/x86_64/make"},
+		},
+	}
+
+	for _, tt := range tests {
+		g, ok := BuildGraph("core", "x86_64", "app", tt.depth, tt.kinds)
+		if !ok {
+			t.Fatalf("%s: package not found", tt.name)
+		}
+		if g.Root != "core/x86_64/app" {
+			t.Errorf("%s: root = %s, want core/x86_64/app", tt.name, g.Root)
+		}
+		if got := nodeIDs(g); !reflect.DeepEqual(got, tt.nodes) {
+			t.Errorf("%s: nodes = %v, want %v", tt.name, got, tt.nodes)
+		}
+		if got := edgeIDs(g); !reflect.DeepEqual(got, tt.edges) {
+			t.Errorf("%s: edges = %v, want %v", tt.name, got, tt.edges)
+		}
+	}
+
+	g, _ := BuildGraph("core", "x86_64", "app", 1, []string{DependKind})
+	for _, n := range g.Nodes {
+		if n.Missing != strings.HasPrefix(n.ID, "missing/") {
+			t.Errorf("node %s: missing = %v", n.ID, n.Missing)
+		}
+	}
+
+	if _, ok := BuildGraph("core", "x86_64", "nothing", -1, []string{DependKind}); ok {
+		t.Error("BuildGraph() of an unknown package: found")
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"sort"
+)
+
+// GroupRepoStats are the statistics of a group in a repository.
+type GroupRepoStats struct {
+	Packages      int
+	InstalledSize int64
+}
+
+// GroupStats are the statistics of a group of packages.
+type GroupStats struct {
+	Name          string
+	Packages      int
+	InstalledSize int64
+	Repos         map[string]*GroupRepoStats
+}
+
+// GroupCondition is the condition of the packages of a group,
+// whose name is given as parameter (the name must match exactly).
+const GroupCondition = "EXISTS (SELECT 1 FROM json_each(packages.groups) WHERE json_each.value = ?)"
+
+// GetGroups returns the statistics of the groups, sorted by name.
+// If repo or arch are set, only the packages of the given repository
+// or architecture are counted.
+func GetGroups(repo, arch string) []*GroupStats {
+	var packages []Package
+
+	dbsingleton.Lock()
+	q := dbsingleton.
+		Model(&Package{}).
+		Select("id, repository, repo_arch, groups, installed_size")
+	if repo != "" {
+		q = q.Where("repository = ?", repo)
+	}
+	if arch != "" {
+		q = q.Where("repo_arch = ?", arch)
+	}
+	q.Find(&packages)
+	dbsingleton.Unlock()
+
+	groups := make(map[string]*GroupStats)
+	for _, p := range packages {
+		for _, name := range p.Groups {
+			g, ok := groups[name]
+			if !ok {
+				g = &GroupStats{
+					Name:  name,
+					Repos: make(map[string]*GroupRepoStats),
+				}
+				groups[name] = g
+			}
+
+			r, ok := g.Repos[p.Repository]
+			if !ok {
+				r = new(GroupRepoStats)
+				g.Repos[p.Repository] = r
+			}
+
+			g.Packages++
+			g.InstalledSize += p.InstalledSize
+			r.Packages++
+			r.InstalledSize += p.InstalledSize
+		}
+	}
+
+	out := make([]*GroupStats, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// FindGroup returns the packages of the group, sorted by repository and name.
+// If repo or arch are set, only the packages of the given repository
+// or architecture are returned.
+func FindGroup(name, repo, arch string) (packages []Package) {
+	dbsingleton.Lock()
+	q := dbsingleton.
+		Preload("Flag").
+		Where(GroupCondition, name)
+	if repo != "" {
+		q = q.Where("repository = ?", repo)
+	}
+	if arch != "" {
+		q = q.Where("repo_arch = ?", arch)
+	}
+	q.Order("repository, name, repo_arch").Find(&packages)
+	dbsingleton.Unlock()
+
+	return
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestFindGroup(t *testing.T) {
+	loadMemoryDb(t)
+	packages := testPackages(5)
+	packages[0].Groups = SqlSlice{"kde"}
+	packages[1].Groups = SqlSlice{"kde-apps", "kde"}
+	packages[2].Groups = SqlSlice{"KDE"}
+	packages[3].Groups = SqlSlice{"k_e"}
+	packages[4].Groups = nil
+	saveTestPackages(t, packages)
+
+	tests := []struct {
+		group string
+		want  []string
+	}{
+		{"kde", []string{"pkg00000", "pkg00001"}},
+		{"KDE", []string{"pkg00002"}},
+		{"k_e", []string{"pkg00003"}},
+		{"k%", nil},
+		{"kde-apps", []string{"pkg00001"}},
+	}
+
+	for _, tt := range tests {
+		var found []Package
+		Search(&found, NewFilterRequest(NewFilter(GroupCondition, "", tt.group)))
+
+		for _, got := range [][]Package{FindGroup(tt.group, "", ""), found} {
+			if len(got) != len(tt.want) {
+				t.Errorf("group %s: got %d packages, want %v", tt.group, len(got), tt.want)
+				continue
+			}
+			for i, p := range got {
+				if p.Name != tt.want[i] {
+					t.Errorf("group %s: got %s, want %s", tt.group, p.Name, tt.want[i])
+				}
+			}
+		}
+	}
+}
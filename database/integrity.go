@@ -0,0 +1,258 @@
+package database
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"pmanager/log"
+
+	"gorm.io/gorm"
+)
+
+// Status of a package file
+const (
+	FileValid    = "valid"
+	FileMissing  = "missing"
+	FileEmpty    = "empty"
+	FileMismatch = "mismatch"
+	FileError    = "error"
+)
+
+// FileIssue is a package whose file is missing, empty or corrupted.
+type FileIssue struct {
+	Package  string `json:"package"`
+	Version  string `json:"version"`
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+}
+
+type fileCheck struct {
+	p      Package
+	path   string
+	hash   FileHash
+	cached bool
+	status string
+	err    error
+}
+
+func findFileHashes() map[string]FileHash {
+	var hashes []FileHash
+	SearchAll(&hashes)
+
+	m := make(map[string]FileHash)
+	for _, h := range hashes {
+		m[h.Path] = h
+	}
+
+	return m
+}
+
+func hashPackageFile(filePath string) (md5Sum, sha256Sum string, err error) {
+	var f *os.File
+	if f, err = os.Open(filePath); err != nil {
+		return
+	}
+	defer f.Close()
+
+	h1, h2 := md5.New(), sha256.New()
+	if _, err = io.Copy(io.MultiWriter(h1, h2), f); err == nil {
+		md5Sum, sha256Sum = fmt.Sprintf("%x", h1.Sum(nil)), fmt.Sprintf("%x", h2.Sum(nil))
+	}
+
+	return
+}
+
+// check checks the presence and the checksums of the file of the package.
+// The file is hashed only if its size or its modification time
+// changed since the last check.
+func (c *fileCheck) check(hashes map[string]FileHash) {
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		c.status = FileMissing
+		return
+	} else if fi.Size() == 0 {
+		c.status = FileEmpty
+		return
+	}
+
+	old, ok := hashes[c.path]
+	if ok && old.Size == fi.Size() && old.ModTime.Equal(fi.ModTime()) {
+		c.hash, c.cached = old, true
+	} else {
+		c.hash = FileHash{Path: c.path, Size: fi.Size(), ModTime: fi.ModTime()}
+		if ok {
+			c.hash.ID, c.hash.CreatedAt = old.ID, old.CreatedAt
+		}
+		if c.hash.Md5Sum, c.hash.Sha256Sum, err = hashPackageFile(c.path); err != nil {
+			log.Debugf("\033[1;31mFailed to hash %s: %s\n\033[m", c.path, err)
+			c.status, c.err = FileError, err
+			return
+		}
+	}
+
+	c.status = FileValid
+	if (c.p.Sha256Sum != "" && c.p.Sha256Sum != c.hash.Sha256Sum) || (c.p.Sha256Sum == "" && c.p.Md5Sum != "" && c.p.Md5Sum != c.hash.Md5Sum) {
+		c.status = FileMismatch
+	}
+}
+
+func (c *fileCheck) issue() FileIssue {
+	i := FileIssue{
+		Package: c.p.ArchRepoName(),
+		Version: c.p.Version,
+		Path:    c.path,
+		Status:  c.status,
+	}
+
+	switch c.status {
+	case FileMismatch:
+		if c.p.Sha256Sum != "" {
+			i.Expected, i.Found = "sha256:"+c.p.Sha256Sum, "sha256:"+c.hash.Sha256Sum
+		} else {
+			i.Expected, i.Found = "md5:"+c.p.Md5Sum, "md5:"+c.hash.Md5Sum
+		}
+	case FileError:
+		i.Found = c.err.Error()
+	}
+
+	return i
+}
+
+// updateFileHashes saves the hashes of the checked files
+// and removes the hashes of the files which are not referenced anymore.
+func updateFileHashes(hashes []FileHash, stale []uint) func(*gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for i := 0; i < len(stale); i += 100 {
+			j := i + 100
+			if j > len(stale) {
+				j = len(stale)
+			}
+			if err := tx.Unscoped().Delete(&FileHash{}, stale[i:j]).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := 0; i < len(hashes); i += 100 {
+			j := i + 100
+			if j > len(hashes) {
+				j = len(hashes)
+			}
+			if err := tx.Save(hashes[i:j]).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// Serializes the integrity checks, which read all the package files.
+var integrityMtx sync.Mutex
+
+// CheckIntegrity checks that the files of the packages exist in base,
+// aren’t empty and match the checksums of the repositories databases.
+// If repos is not empty, only the packages of these repositories are checked.
+// Concurrent calls are run one after the other.
+func CheckIntegrity(base string, repos []string) *UpdateResult {
+	integrityMtx.Lock()
+	defer integrityMtx.Unlock()
+
+	var (
+		res      = newUpdateResult()
+		packages []Package
+	)
+	defer res.Time("total", time.Now())
+
+	dbsingleton.Lock()
+	q := dbsingleton.
+		Model(&Package{}).
+		Select("id, name, version, repository, repo_arch, repo_path, filename, md5_sum, sha256_sum")
+	if len(repos) > 0 {
+		q = q.Where("repository IN ?", repos)
+	}
+	q.Find(&packages)
+	dbsingleton.Unlock()
+
+	var (
+		hashes = findFileHashes()
+		checks = make([]*fileCheck, len(packages))
+		tasks  = make([]func(), len(packages))
+	)
+	for i, p := range packages {
+		c := &fileCheck{p: p, path: path.Join(base, p.Dir(), p.Filename)}
+		checks[i] = c
+		tasks[i] = func() { c.check(hashes) }
+	}
+
+	begin := time.Now()
+	runTasks(repoWorkers, tasks)
+	res.Time("files", begin)
+
+	var (
+		count  = make(map[string]int)
+		issues = []FileIssue{}
+		saved  []FileHash
+		hashed int
+	)
+	for _, c := range checks {
+		count[c.status]++
+		if c.hash.Path != "" {
+			saved = append(saved, c.hash)
+			if !c.cached {
+				hashed++
+			}
+		}
+		if c.status == FileValid {
+			continue
+		}
+
+		i := c.issue()
+		issues = append(issues, i)
+		switch c.status {
+		case FileMismatch:
+			res.Error("package "+i.Package, fmt.Sprintf("Checksum mismatch of %s: expected %s, found %s", i.Path, i.Expected, i.Found))
+		case FileError:
+			res.Error("package "+i.Package, fmt.Sprintf("Failed to read %s: %s", i.Path, i.Found))
+		default:
+			res.Error("package "+i.Package, fmt.Sprintf("File %s is %s", i.Path, c.status))
+		}
+	}
+
+	// If all the repositories were checked, the hashes
+	// of the files which weren’t found are obsolete.
+	var stale []uint
+	if len(repos) == 0 {
+		checked := make(map[string]bool)
+		for _, h := range saved {
+			checked[h.Path] = true
+		}
+		for p, h := range hashes {
+			if !checked[p] {
+				stale = append(stale, h.ID)
+			}
+		}
+	}
+
+	res.Set("packages", count)
+	res.Set("files", issues)
+	res.Set("files_hashed", hashed)
+	res.Set("files_cached", len(saved)-hashed)
+
+	dbsingleton.Lock()
+	defer dbsingleton.Unlock()
+
+	if err := dbsingleton.Transaction(updateFileHashes(saved, stale)); err != nil {
+		log.Errorf("Failed to save the hashes of the files: %s\n", err)
+		res.Warning("database", err)
+	}
+
+	return res
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writePackageFile(t *testing.T, base string, p Package, content string) {
+	t.Helper()
+
+	fp := filepath.Join(base, p.Dir(), p.Filename)
+	if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileStatuses(res *UpdateResult) map[string]string {
+	statuses := make(map[string]string)
+	for _, i := range res.Map()["files"].([]FileIssue) {
+		statuses[i.Package] = i.Status
+	}
+
+	return statuses
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	base := t.TempDir()
+	loadTestDb(t)
+
+	const content = "package content"
+	sha256Sum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	md5Sum := fmt.Sprintf("%x", md5.Sum([]byte(content)))
+
+	packages := testPackages(7)
+	for i := range packages {
+		packages[i].Sha256Sum = sha256Sum
+	}
+	packages[4].Sha256Sum, packages[4].Md5Sum = "", md5Sum
+	packages[5].Sha256Sum, packages[5].Md5Sum = "", md5Sum
+	packages[6].Repository, packages[6].RepoPath = "main", "main"
+	saveTestPackages(t, packages)
+
+	writePackageFile(t, base, packages[0], content)
+	// pkg00001 is missing
+	writePackageFile(t, base, packages[2], "")
+	writePackageFile(t, base, packages[3], "corrupted")
+	writePackageFile(t, base, packages[4], content)
+	writePackageFile(t, base, packages[5], "corrupted")
+	writePackageFile(t, base, packages[6], content)
+
+	res := CheckIntegrity(base, nil)
+	want := map[string]string{
+		"core/x86_64/pkg00001": FileMissing,
+		"core/x86_64/pkg00002": FileEmpty,
+		"core/x86_64/pkg00003": FileMismatch,
+		"core/x86_64/pkg00005": FileMismatch,
+	}
+	if got := fileStatuses(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	data := res.Map()
+	if count := data["packages"].(map[string]int); count[FileValid] != 3 || count[FileMismatch] != 2 {
+		t.Errorf("packages = %v, want 3 valid and 2 mismatches", count)
+	}
+	for _, i := range data["files"].([]FileIssue) {
+		if i.Package == "core/x86_64/pkg00005" && i.Expected != "md5:"+md5Sum {
+			t.Errorf("the md5 sum must be checked without sha256 sum, expected %s", i.Expected)
+		}
+	}
+	if res.Status() != UpdatePartial {
+		t.Errorf("got status %s, want %s", res.Status(), UpdatePartial)
+	}
+
+	checkCache := func(step string, hashed, cached int) {
+		t.Helper()
+
+		data := res.Map()
+		if data["files_hashed"] != hashed || data["files_cached"] != cached {
+			t.Errorf("%s: %v files hashed and %v cached, want %d and %d", step, data["files_hashed"], data["files_cached"], hashed, cached)
+		}
+	}
+	checkCache("first run", 5, 0)
+
+	// The files are not hashed again unless they changed.
+	res = CheckIntegrity(base, nil)
+	checkCache("second run", 0, 5)
+	if got := fileStatuses(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses from the cache = %v, want %v", got, want)
+	}
+
+	writePackageFile(t, base, packages[3], content)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(base, packages[3].Dir(), packages[3].Filename), later, later); err != nil {
+		t.Fatal(err)
+	}
+	res = CheckIntegrity(base, nil)
+	checkCache("touched file", 1, 4)
+	delete(want, "core/x86_64/pkg00003")
+	if got := fileStatuses(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses after the fix = %v, want %v", got, want)
+	}
+
+	// The hash of a removed package is only deleted by a full run.
+	dbsingleton.Unscoped().Where("repository = ?", "main").Delete(&Package{})
+	CheckIntegrity(base, []string{"core"})
+	if n := countRows(t, &FileHash{}); n != 5 {
+		t.Errorf("got %d hashes after a partial run, want 5", n)
+	}
+	CheckIntegrity(base, nil)
+	if n := countRows(t, &FileHash{}); n != 4 {
+		t.Errorf("got %d hashes after a full run, want 4", n)
+	}
+}
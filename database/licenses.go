@@ -0,0 +1,190 @@
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"pmanager/util/spdx"
+)
+
+// Issues of the licenses of a package
+const (
+	LicenseMissing    = "no_license"          // no license
+	LicenseNonSpdx    = "non_spdx"            // identifier which isn’t SPDX
+	LicenseDeprecated = "deprecated"          // deprecated SPDX identifier
+	LicenseNoFile     = "custom_without_file" // custom license without license file
+)
+
+// LicenseStats are the packages using a license.
+type LicenseStats struct {
+	Name     string
+	Status   string // SPDX status of the identifier (see util/spdx)
+	Packages []Package
+}
+
+// LicenseIssue are the issues of the licenses of a package.
+type LicenseIssue struct {
+	Package     Package
+	Issues      []string
+	Identifiers []string // Identifiers causing the issues
+}
+
+func findLicensePackages(repo, arch string, files bool) (packages []Package) {
+	fields := "id, name, version, repository, repo_arch, base, licenses"
+	if files {
+		fields += ", files"
+	}
+
+	dbsingleton.Lock()
+	defer dbsingleton.Unlock()
+
+	q := dbsingleton.
+		Model(&Package{}).
+		Select(fields)
+	if repo != "" {
+		q = q.Where("repository = ?", repo)
+	}
+	if arch != "" {
+		q = q.Where("repo_arch = ?", arch)
+	}
+	q.Order("repository, name, repo_arch").Find(&packages)
+
+	return
+}
+
+func licenseIdentifiers(p Package) (ids []string) {
+	for _, l := range p.Licenses {
+		licenses, exceptions := spdx.Identifiers(l)
+		ids = append(ids, licenses...)
+		ids = append(ids, exceptions...)
+	}
+
+	return
+}
+
+// GetLicenses returns the license identifiers used by the packages,
+// sorted by decreasing number of packages.
+// If repo or arch are set, only the packages of the given repository
+// or architecture are counted.
+func GetLicenses(repo, arch string) []*LicenseStats {
+	stats := make(map[string]*LicenseStats)
+	for _, p := range findLicensePackages(repo, arch, false) {
+		for _, id := range licenseIdentifiers(p) {
+			st, ok := stats[id]
+			if !ok {
+				st = &LicenseStats{Name: id, Status: spdx.Status(id)}
+				if spdx.IsException(id) {
+					st.Status = spdx.ExceptionStatus(id)
+				}
+				stats[id] = st
+			}
+			st.Packages = append(st.Packages, p)
+		}
+	}
+
+	out := make([]*LicenseStats, 0, len(stats))
+	for _, st := range stats {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].Packages) != len(out[j].Packages) {
+			return len(out[i].Packages) > len(out[j].Packages)
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// hasLicenseFile returns true if the package provides
+// a license file in usr/share/licenses/<pkgname>/ (or <pkgbase>/).
+func hasLicenseFile(p Package) bool {
+	prefixes := []string{
+		"usr/share/licenses/" + p.Name + "/",
+		"usr/share/licenses/" + p.PkgBase() + "/",
+	}
+
+	for _, f := range p.Files {
+		if strings.HasSuffix(f, "/") {
+			continue
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(f, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func appendIssue(issues []string, issue string) []string {
+	for _, i := range issues {
+		if i == issue {
+			return issues
+		}
+	}
+
+	return append(issues, issue)
+}
+
+// checkLicenses returns the issues of the licenses of the package.
+func checkLicenses(p Package) (li LicenseIssue) {
+	li.Package = p
+
+	var licenses, exceptions []string
+	for _, l := range p.Licenses {
+		ls, es := spdx.Identifiers(l)
+		licenses, exceptions = append(licenses, ls...), append(exceptions, es...)
+	}
+	if len(licenses) == 0 {
+		li.Issues = []string{LicenseMissing}
+		return
+	}
+
+	custom := false
+	for _, id := range licenses {
+		switch spdx.Status(id) {
+		case spdx.Unknown:
+			li.Issues = appendIssue(li.Issues, LicenseNonSpdx)
+			li.Identifiers = append(li.Identifiers, id)
+		case spdx.Deprecated:
+			li.Issues = appendIssue(li.Issues, LicenseDeprecated)
+			li.Identifiers = append(li.Identifiers, id)
+		case spdx.Custom:
+			custom = true
+		}
+	}
+	for _, id := range exceptions {
+		switch spdx.ExceptionStatus(id) {
+		case spdx.Unknown:
+			li.Issues = appendIssue(li.Issues, LicenseNonSpdx)
+			li.Identifiers = append(li.Identifiers, id)
+		case spdx.Deprecated:
+			li.Issues = appendIssue(li.Issues, LicenseDeprecated)
+			li.Identifiers = append(li.Identifiers, id)
+		}
+	}
+
+	if custom && !hasLicenseFile(p) {
+		li.Issues = append(li.Issues, LicenseNoFile)
+	}
+
+	return
+}
+
+// GetLicenseIssues returns the packages whose licenses have issues:
+// no license, identifiers which aren’t SPDX (or are deprecated)
+// and custom licenses without license file.
+// If repo or arch are set, only the packages of the given repository
+// or architecture are checked.
+func GetLicenseIssues(repo, arch string) (issues []LicenseIssue) {
+	for _, p := range findLicensePackages(repo, arch, true) {
+		if li := checkLicenses(p); len(li.Issues) > 0 {
+			li.Package.Files = nil
+			issues = append(issues, li)
+		}
+	}
+
+	return
+}
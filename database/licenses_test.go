@@ -0,0 +1,41 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckLicenses(t *testing.T) {
+	tests := []struct {
+		name        string
+		licenses    []string
+		files       []string
+		issues      []string
+		identifiers []string
+	}{
+		{"spdx", []string{"GPL-3.0-or-later"}, nil, nil, nil},
+		{"no license", nil, nil, []string{LicenseMissing}, nil},
+		{"custom without file", []string{"custom:Foo"}, []string{"usr/", "usr/bin/foo"}, []string{LicenseNoFile}, nil},
+		{"custom with file", []string{"custom:Foo"}, []string{"usr/share/licenses/foo/LICENSE"}, nil, nil},
+		{"custom with file of pkgbase", []string{"custom:Foo"}, []string{"usr/share/licenses/foo-base/LICENSE"}, nil, nil},
+		{"custom with directory only", []string{"custom:Foo"}, []string{"usr/share/licenses/", "usr/share/licenses/foo/"}, []string{LicenseNoFile}, nil},
+		{"custom with file of another package", []string{"custom:Foo"}, []string{"usr/share/licenses/foobar/LICENSE"}, []string{LicenseNoFile}, nil},
+		{"exception", []string{"GPL-2.0-or-later WITH Classpath-exception-2.0"}, nil, nil, nil},
+		{"unknown exception", []string{"GPL-2.0-or-later WITH Foo-exception"}, nil, []string{LicenseNonSpdx}, []string{"Foo-exception"}},
+		{"deprecated", []string{"GPL-2.0+"}, nil, []string{LicenseDeprecated}, []string{"GPL-2.0+"}},
+		{"non spdx", []string{"GPL"}, nil, []string{LicenseNonSpdx}, []string{"GPL"}},
+		{"several issues", []string{"GPL", "GPL-2.0+", "custom:Foo"}, nil, []string{LicenseNonSpdx, LicenseDeprecated, LicenseNoFile}, []string{"GPL", "GPL-2.0+"}},
+	}
+
+	for _, tt := range tests {
+		p := Package{Name: "foo", Base: "foo-base", Licenses: tt.licenses, Files: tt.files}
+		li := checkLicenses(p)
+
+		if !reflect.DeepEqual(li.Issues, tt.issues) {
+			t.Errorf("%s: issues = %v, want %v", tt.name, li.Issues, tt.issues)
+		}
+		if !reflect.DeepEqual(li.Identifiers, tt.identifiers) {
+			t.Errorf("%s: identifiers = %v, want %v", tt.name, li.Identifiers, tt.identifiers)
+		}
+	}
+}
@@ -22,6 +22,9 @@ func Load(uri string) {
 		log.Fatalf("Failed to load the database: %s\n", err)
 	}
 
+	m := dbsingleton.Migrator()
+	missingProvides := m.HasTable(&Package{}) && !m.HasColumn(&Package{}, "Provides")
+
 	err = dbsingleton.AutoMigrate(
 		&Git{},
 		&Flag{},
@@ -29,9 +32,21 @@ func Load(uri string) {
 		&Repo{},
 		&Mirror{},
 		&Country{},
+		&MirrorApplication{},
+		&RepoState{},
+		&FileHash{},
 	)
 
 	if err != nil {
 		log.Fatalf("Failed to update the schema database: %s\n", err)
 	}
+
+	// The provisions of the packages are only read when their repository is scanned,
+	// so the states are removed to rescan all the repositories at the next update.
+	if missingProvides {
+		if err = dbsingleton.Unscoped().Where("1 = 1").Delete(&RepoState{}).Error; err != nil {
+			log.Fatalf("Failed to reset the states of the repositories: %s\n", err)
+		}
+		log.Println("Provisions of the packages added, all the repositories will be rescanned at the next update")
+	}
 }
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadResetsStatesWithoutProvides(t *testing.T) {
+	uri := filepath.Join(t.TempDir(), "pmanager.db")
+
+	Load(uri)
+	if err := dbsingleton.Create(&RepoState{Name: "core", Arch: "x86_64", Hash: "0123"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Database of a version which didn't store the provisions
+	if err := dbsingleton.Migrator().DropColumn(&Package{}, "Provides"); err != nil {
+		t.Fatal(err)
+	}
+	closeDb := func() {
+		if db, err := dbsingleton.DB.DB(); err == nil {
+			db.Close()
+		}
+	}
+	closeDb()
+
+	Load(uri)
+	defer closeDb()
+	if !dbsingleton.Migrator().HasColumn(&Package{}, "Provides") {
+		t.Fatal("provides column not added")
+	}
+	if states := findRepoStates(); len(states) != 0 {
+		t.Errorf("got %d repository states after the migration, want none", len(states))
+	}
+
+	// The states are kept once the column exists.
+	if err := dbsingleton.Create(&RepoState{Name: "core", Arch: "x86_64", Hash: "0123"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	closeDb()
+	Load(uri)
+	if states := findRepoStates(); len(states) != 1 {
+		t.Errorf("got %d repository states after a reload, want 1", len(states))
+	}
+}
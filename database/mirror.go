@@ -2,111 +2,226 @@ package database
 
 import (
 	"bufio"
-	"crypto/md5"
 	"fmt"
 	"io"
-	"net/http"
+	"net/url"
+	"os"
 	"pmanager/log"
+	"pmanager/util/geoip"
+	"pmanager/util/pacman"
 	"pmanager/util/resource"
 	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	// Repositories checked on the mirrors in addition to the ones of pacman.conf
+	mirrorExtraRepos []string
+
+	// Architectures of the repositories checked on the mirrors
+	mirrorArchs []string
+)
+
+// InitMirrorRepos configures the repositories checked on the mirrors.
+// - extraRepos : repositories to check even if they aren’t enabled in pacman.conf
+// - archs      : architectures of the repositories (the ones of repository.architectures)
+func InitMirrorRepos(extraRepos, archs []string) {
+	mirrorExtraRepos, mirrorArchs = extraRepos, archs
+}
+
 func readPacmanConf(uri string) (repos []string, err error) {
-	var data io.Reader
-	if data, err = resource.Open(uri); err != nil {
+	var cnf *pacman.Config
+	if cnf, err = pacman.Parse(uri); err != nil {
 		return
 	}
 
-	sc := bufio.NewScanner(data)
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
-		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
-			continue
-		}
-		l := len(line)
-		name := line[1 : l-1]
-		if name != "options" {
-			repos = append(repos, name)
-		}
+	for _, r := range cnf.Disabled() {
+		log.Debugf("Repo %s is disabled in %s\n", r.Name, uri)
 	}
 
+	return cnf.Names(), nil
+}
+
+// addExtraRepos appends the extra repositories which aren’t already in repos.
+func addExtraRepos(repos, extraRepos []string) []string {
+	known := make(map[string]bool)
 	for _, r := range repos {
-		if r == "build" {
-			return
+		known[r] = true
+	}
+
+	for _, r := range extraRepos {
+		if !known[r] {
+			known[r] = true
+			repos = append(repos, r)
 		}
 	}
 
-	return append(repos, "build"), nil
+	return repos
 }
 
-func newRepos(repos []string, mirrorName string) (out []Repo) {
-	out = make([]Repo, len(repos))
+func newRepos(repos, archs []string, mirror Mirror) (out []Repo) {
+	out = make([]Repo, 0, len(repos)*len(archs))
 
-	for i, r := range repos {
-		out[i].Name, out[i].mirrorName = r, mirrorName
+	for _, r := range repos {
+		for _, a := range archs {
+			out = append(out, Repo{
+				Name: r,
+				Arch: a,
+				url:  mirror.RepoURL(r, a),
+			})
+		}
 	}
 
 	return out
 }
 
-func newMirror(name string, repos []string) (mirror Mirror) {
-	mirror.Name = strings.Replace(name, "$repo", "", 1)
-	mirror.Repos = newRepos(repos, mirror.Name)
+// MirrorName returns the base URL of a mirror server,
+// ie. the part of the server URL before the first $repo or $arch variable.
+func MirrorName(server string) string {
+	i := len(server)
+	for _, v := range []string{"$repo", "$arch"} {
+		if j := strings.Index(server, v); j >= 0 && j < i {
+			i = j
+		}
+	}
+
+	return server[:i]
+}
+
+// newMirror returns a mirror from the server URL of the mirrorlist.
+// The name of the mirror is the part of the URL before the first $repo or $arch.
+func newMirror(server string, repos, archs []string) (mirror Mirror) {
+	mirror.Name, mirror.Server = MirrorName(server), server
+	mirror.Repos = newRepos(repos, archs, mirror)
 
 	return
 }
 
-func checkMirrorIsOnline(mirror *Mirror, repos chan *Repo, mirrors chan *Mirror, wg *sync.WaitGroup) {
-	defer wg.Done()
+func locateMirror(mirror *Mirror, geo *geoip.Reader) {
+	if geo == nil {
+		return
+	}
 
-	if mirror.Online = resource.Exists(mirror.Name); !mirror.Online {
-		log.Debugf("\033[1;31mMirror %s is not online\n\033[m", mirror.Name)
+	loc, err := geo.LookupHost(mirror.Name)
+	if err != nil {
+		log.Debugf("\033[1;31mFailed to locate mirror %s: %s\n\033[m", mirror.Name, err)
 		return
 	}
 
-	log.Debugf("\033[1;32mMirror %s is online\n\033[m", mirror.Name)
+	mirror.CountryCode, mirror.ContinentCode = loc.Country, loc.Continent
+}
 
-	for i := range mirror.Repos {
-		repos <- &mirror.Repos[i]
+func checkMirrorIsOnline(mirror *Mirror, geo *geoip.Reader) {
+	locateMirror(mirror, geo)
+
+	err := mirrorChecker.head(mirror.Name)
+	if err != nil {
+		mirror.Online, mirror.Error = false, err.Error()
+		log.Debugf("\033[1;31mMirror %s is not online: %s\n\033[m", mirror.Name, err)
+	} else {
+		mirror.Online = true
+		log.Debugf("\033[1;32mMirror %s is online\n\033[m", mirror.Name)
 	}
-	mirrors <- mirror
+
+	// The capabilities are checked even if the mirror is offline,
+	// it may only be reachable over HTTPS or one IP version,
+	// but not if the host cannot be reached at all.
+	if err != nil && isUnreachable(err) {
+		return
+	}
+	checkMirrorCapabilities(mirror)
 }
 
-func getRepoMd5(repo *Repo, wg *sync.WaitGroup) {
-	defer wg.Done()
+// httpsURL returns the given URL with the https scheme.
+func httpsURL(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
 
-	url := fmt.Sprintf("%s%s/%s.db.tar.gz", repo.mirrorName, repo.Name, repo.Name)
+	if u.Scheme == "http" {
+		u.Scheme = "https"
+		if u.Port() != "" {
+			u.Host = u.Hostname()
+			if strings.Contains(u.Host, ":") {
+				u.Host = "[" + u.Host + "]"
+			}
+		}
+	}
 
-	resp, err := http.Get(url)
-	defer resp.Body.Close()
+	return u.String(), nil
+}
 
-	if err != nil {
-		log.Debugf("\033[1;31mFailed to get %s: %s\n[033m", url, err)
-		return
+// checkMirrorCapabilities checks the protocols served by the mirror:
+// HTTPS (with a valid certificate), redirection from HTTP to HTTPS,
+// IPv4 and IPv6. The failures of the checks are recorded in CapabilityError.
+// If the mirror is offline and does not serve HTTPS either,
+// the other checks are skipped.
+func checkMirrorCapabilities(mirror *Mirror) {
+	var failures []string
+	fail := func(check string, err error) {
+		failures = append(failures, check+": "+err.Error())
+		log.Debugf("\033[1;31mMirror %s: %s check failed: %s\n\033[m", mirror.Name, check, err)
+	}
+	defer func() { mirror.CapabilityError = strings.Join(failures, "; ") }()
+
+	if uri, err := httpsURL(mirror.Name); err != nil {
+		fail("https", err)
+	} else if expiry, err := mirrorChecker.certificate(uri); err != nil {
+		fail("https", err)
+	} else {
+		mirror.Https, mirror.CertificateExpiry = true, expiry
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("[%d] %s", resp.StatusCode, resp.Status)
-		log.Debugf("\033[1;31mfailed to check md5 from %s: %s\n\033[m", url, err)
+	if !mirror.Online && !mirror.Https {
 		return
 	}
 
-	var b []byte
-	if b, err = io.ReadAll(resp.Body); err != nil {
-		log.Debugf("\033[1;31mfailed to parse md5 from %s: %s\n\033[m", url, err)
+	if strings.HasPrefix(mirror.Name, "http://") {
+		redirect, err := mirrorChecker.redirectsToHttps(mirror.Name)
+		if err != nil {
+			fail("redirect", err)
+		}
+		mirror.RedirectsToHttps = redirect
+	}
+
+	if err := mirrorChecker.headWith(mirrorChecker.client4, mirror.Name); err != nil {
+		fail("ipv4", err)
+	} else {
+		mirror.IPv4 = true
+	}
+	if err := mirrorChecker.headWith(mirrorChecker.client6, mirror.Name); err != nil {
+		fail("ipv6", err)
+	} else {
+		mirror.IPv6 = true
 	}
-	repo.md5 = fmt.Sprintf("%x", md5.Sum(b))
-	log.Debugf("\033[1;32mcheck md5 from %s successful\n\033[m", url)
 }
 
-func checkMirrorMd5(mirror, mainMirror *Mirror, wg *sync.WaitGroup) {
-	defer wg.Done()
+// certificateExpiresSoon returns true if the mirror serves HTTPS
+// and its certificate expires before the warning delay.
+func certificateExpiresSoon(mirror Mirror) bool {
+	return mirror.Https && time.Until(mirror.CertificateExpiry) < mirrorChecker.certWarning
+}
+
+func getRepoMd5(repo *Repo) {
+	url := fmt.Sprintf("%s%s.db.tar.gz", repo.url, repo.Name)
 
-	if !mirror.Online {
+	md5, err := mirrorChecker.md5(url)
+	if err != nil {
+		repo.Error = err.Error()
+		log.Debugf("\033[1;31mFailed to check md5 from %s: %s\n\033[m", url, err)
+		return
+	}
+
+	repo.md5 = md5
+	log.Debugf("\033[1;32mcheck md5 from %s successful\n\033[m", url)
+}
+
+func checkMirrorMd5(mirror, mainMirror *Mirror) {
+	if !mirror.Online || mainMirror == nil {
 		return
 	}
 
@@ -114,73 +229,69 @@ func checkMirrorMd5(mirror, mainMirror *Mirror, wg *sync.WaitGroup) {
 		repo := &mirror.Repos[i]
 		repo.Sync = repo.md5 != "" && repo.md5 == mainMirror.Repos[i].md5
 		if repo.Sync {
-			log.Debugf("\033[1;32m%s%s is synced\n\033[m", repo.mirrorName, repo.Name)
+			log.Debugf("\033[1;32m%s is synced\n\033[m", repo.url)
 		} else {
-			log.Debugf("\033[1;31m%s%s is not synced\n\033[m", repo.mirrorName, repo.Name)
+			log.Debugf("\033[1;31m%s is not synced\n\033[m", repo.url)
 		}
 	}
 }
 
-func sendClose[T any](wg *sync.WaitGroup, c chan T, done chan bool) {
-	wg.Wait()
-	close(c)
-	done <- true
-}
-
-func searchMirrorUpdate(pacmanConf, pacmanMirrors, mainMirrorName string) (countries []Country, err error) {
-	var repoNames []string
-	if repoNames, err = readPacmanConf(pacmanConf); err != nil {
-		return
+// mergeMirrorList adds the mirrors of the managed mirrorlist
+// which aren’t already in the mirrorlist, under their country.
+func mergeMirrorList(countries, managed []Country) []Country {
+	known := make(map[string]bool)
+	index := make(map[string]int)
+	for i, c := range countries {
+		index[c.Name] = i
+		for _, m := range c.Mirrors {
+			known[m.Name] = true
+		}
 	}
 
-	log.Debugln("Found repos:")
-	for _, r := range repoNames {
-		log.Debugln(" -", r)
+	for _, c := range managed {
+		for _, m := range c.Mirrors {
+			if known[m.Name] {
+				continue
+			}
+			known[m.Name] = true
+
+			i, ok := index[c.Name]
+			if !ok {
+				i = len(countries)
+				index[c.Name] = i
+				countries = append(countries, Country{Name: c.Name})
+			}
+			countries[i].Mirrors = append(countries[i].Mirrors, m)
+		}
 	}
 
-	var data io.Reader
+	return countries
+}
+
+func readMirrorList(pacmanMirrors string, repoNames, archs []string) (countries []Country, err error) {
+	var data io.ReadCloser
 	if data, err = resource.Open(pacmanMirrors); err != nil {
 		return
 	}
+	defer data.Close()
 
 	var (
-		sc                           = bufio.NewScanner(data)
-		repos                        = make(chan *Repo, bufferSize)
-		mirrors                      = make(chan *Mirror, bufferSize)
-		done                         = make(chan bool, 2)
-		country                      *Country
-		mainMirror                   *Mirror
-		wgOnline, wgRepos, wgMirrors sync.WaitGroup
+		sc      = bufio.NewScanner(data)
+		country *Country
 	)
 
 	addCountry := func() {
 		if country != nil && len(country.Mirrors) > 0 {
-			for i := range country.Mirrors {
-				mirror := &country.Mirrors[i]
-				if mirror.Name == mainMirrorName {
-					mainMirror = mirror
-				}
-				wgOnline.Add(1)
-				go checkMirrorIsOnline(mirror, repos, mirrors, &wgOnline)
-			}
 			countries = append(countries, *country)
 		}
 	}
 
-	go func() {
-		for repo := range repos {
-			wgRepos.Add(1)
-			go getRepoMd5(repo, &wgRepos)
-		}
-		done <- true
-	}()
-
 	for sc.Scan() {
 		line := sc.Text()
 		i := strings.Index(line, "Server = ")
 
-		if i >= 0 {
-			country.Mirrors = append(country.Mirrors, newMirror(strings.TrimSpace(line[i+8:]), repoNames))
+		if i >= 0 && country != nil {
+			country.Mirrors = append(country.Mirrors, newMirror(strings.TrimSpace(line[i+8:]), repoNames, archs))
 		} else if strings.HasPrefix(line, "#") {
 			addCountry()
 			country = new(Country)
@@ -189,18 +300,120 @@ func searchMirrorUpdate(pacmanConf, pacmanMirrors, mainMirrorName string) (count
 	}
 	addCountry()
 
-	wgOnline.Wait()
+	return
+}
+
+// searchMirrorUpdate checks the mirrors of the mirrorlist, of the managed mirrorlist
+// and of the applications.
+// The problems of the mirrors and the durations of the checks are recorded in the result.
+func searchMirrorUpdate(
+	res *UpdateResult,
+	pacmanConf,
+	pacmanMirrors,
+	managedMirrors,
+	mainMirrorName,
+	geoipDb string,
+	applications []MirrorApplication,
+) (countries []Country, err error) {
+	var repoNames []string
+	if repoNames, err = readPacmanConf(pacmanConf); err != nil {
+		return
+	}
+	repoNames = addExtraRepos(repoNames, mirrorExtraRepos)
+
+	archs := mirrorArchs
+	if len(archs) == 0 {
+		archs = []string{defaultArch(archs)}
+	}
+
+	log.Debugln("Found repos:")
+	for _, r := range repoNames {
+		log.Debugln(" -", r)
+	}
+	log.Debugln("Architectures:", strings.Join(archs, ", "))
+
+	if countries, err = readMirrorList(pacmanMirrors, repoNames, archs); err != nil {
+		return
+	}
+
+	// The approved applications are only written in the managed mirrorlist.
+	if managedMirrors != "" && managedMirrors != pacmanMirrors && resource.IsFile(managedMirrors) {
+		var managed []Country
+		if managed, err = readMirrorList(managedMirrors, repoNames, archs); err != nil {
+			return
+		}
+		countries = mergeMirrorList(countries, managed)
+	}
+
+	var geo *geoip.Reader
+	if geoipDb != "" {
+		if geo, err = geoip.Open(geoipDb); err != nil {
+			log.Warnf("Failed to load the GeoIP database: %s\n", err)
+			res.Warning("geoip "+geoipDb, err)
+			geo, err = nil, nil
+		} else {
+			defer geo.Close()
+		}
+	}
+
+	var (
+		mirrors    []*Mirror
+		mainMirror *Mirror
+		tasks      []func()
+	)
+
+	check := func(mirror *Mirror) func() {
+		return func() {
+			begin := time.Now()
+			checkMirrorIsOnline(mirror, geo)
+			res.Time("mirror "+mirror.Name, begin)
+		}
+	}
+
+	for i := range countries {
+		for j := range countries[i].Mirrors {
+			mirror := &countries[i].Mirrors[j]
+			if mirror.Name == mainMirrorName {
+				mainMirror = mirror
+			}
+			mirrors = append(mirrors, mirror)
+			tasks = append(tasks, check(mirror))
+		}
+	}
+
+	appMirrors := make([]Mirror, len(applications))
+	for i, a := range applications {
+		mirror := &appMirrors[i]
+		*mirror = newMirror(a.URL+"$repo", repoNames, archs)
+		mirrors = append(mirrors, mirror)
+		tasks = append(tasks, check(mirror))
+	}
+	mirrorChecker.run(tasks)
+
+	if mainMirror == nil {
+		log.Warnf("Main mirror %s not found in the mirrorlist\n", mainMirrorName)
+		res.Warning("mirror "+mainMirrorName, "Main mirror not found in the mirrorlist")
+	}
 
-	go sendClose(&wgRepos, repos, done)
-	<-done
-	<-done
+	tasks = nil
+	for _, mirror := range mirrors {
+		if !mirror.Online {
+			continue
+		}
+		for i := range mirror.Repos {
+			repo := &mirror.Repos[i]
+			tasks = append(tasks, func() { getRepoMd5(repo) })
+		}
+	}
+	mirrorChecker.run(tasks)
 
-	close(mirrors)
-	for mirror := range mirrors {
-		wgMirrors.Add(1)
-		go checkMirrorMd5(mirror, mainMirror, &wgMirrors)
+	for _, mirror := range mirrors {
+		checkMirrorMd5(mirror, mainMirror)
+	}
+
+	for i := range applications {
+		checkApplication(&applications[i], appMirrors[i])
 	}
-	wgMirrors.Wait()
 
 	sort.Slice(countries, func(i, j int) bool {
 		c1, c2 := countries[i].Name, countries[j].Name
@@ -214,20 +427,147 @@ func searchMirrorUpdate(pacmanConf, pacmanMirrors, mainMirrorName string) (count
 	})
 
 	log.Debugln("Found mirrors:")
+	offline := 0
 	for _, c := range countries {
 		log.Debugln(" *", c.Name)
 		for _, m := range c.Mirrors {
 			online := "\033[1;32monline\033[m"
 			if !m.Online {
 				online = "\033[1;31moffline\033[m"
+				offline++
 			}
 			log.Debugf("    → %s (%s)\n", m.Name, online)
+			reportMirror(res, m)
 		}
 	}
+	res.Set("mirrors_offline", offline)
 
 	return
 }
 
+// reportMirror records the problems of the mirror in the result of the update.
+// They are warnings: the availability of third-party mirrors
+// doesn't tell if the update itself failed.
+func reportMirror(res *UpdateResult, m Mirror) {
+	target := "mirror " + m.Name
+
+	if m.CapabilityError != "" {
+		res.Warning(target, "Failed capability checks: "+m.CapabilityError)
+	}
+
+	if !m.Online {
+		res.Warning(target, "Offline: "+m.Error)
+		return
+	}
+
+	for _, r := range m.Repos {
+		if r.Error != "" {
+			res.Warning(target, fmt.Sprintf("%s/%s: %s", r.Name, r.Arch, r.Error))
+		}
+	}
+
+	if certificateExpiresSoon(m) {
+		expiry := m.CertificateExpiry.Format(time.RFC1123)
+		log.Warnf("The certificate of the mirror %s expires on %s\n", m.Name, expiry)
+		res.Warning(target, "The certificate expires on "+expiry)
+	}
+}
+
+// checkApplication records the result of the check
+// of a mirror application: the mirror must be online
+// and synced for all the repositories.
+func checkApplication(a *MirrorApplication, mirror Mirror) {
+	a.Checks++
+	a.LastCheck = time.Now()
+	a.LastError = mirror.Error
+
+	if mirror.Online {
+		for _, r := range mirror.Repos {
+			if !r.Sync {
+				a.LastError = fmt.Sprintf("Repository %s (%s) is not synced", r.Name, r.Arch)
+				if r.Error != "" {
+					a.LastError += ": " + r.Error
+				}
+				break
+			}
+		}
+	}
+
+	if a.LastError == "" {
+		a.Successes++
+		log.Debugf("\033[1;32mMirror application %s successfully checked\n\033[m", a.URL)
+	} else {
+		log.Debugf("\033[1;31mMirror application %s failed: %s\n\033[m", a.URL, a.LastError)
+	}
+}
+
+// addToMirrorList adds the mirrors of the applications to the mirrorlist file,
+// under the header of their country (the header is created if needed).
+func addToMirrorList(uri string, applications []MirrorApplication) (err error) {
+	var lines []string
+
+	if resource.IsFile(uri) {
+		var data io.ReadCloser
+		if data, err = resource.Open(uri); err != nil {
+			return
+		}
+		sc := bufio.NewScanner(data)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		data.Close()
+	}
+
+	for _, a := range applications {
+		server := "Server = " + a.URL + "$repo"
+		header, pos := "# "+a.Country, -1
+
+		for i, line := range lines {
+			if strings.TrimSpace(line) == header {
+				pos = i + 1
+				for pos < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[pos]), "Server") {
+					pos++
+				}
+				break
+			}
+		}
+
+		if pos < 0 {
+			if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+				lines = append(lines, "")
+			}
+			lines = append(lines, header, server)
+		} else {
+			lines = append(lines[:pos], append([]string{server}, lines[pos:]...)...)
+		}
+	}
+
+	var f *os.File
+	if f, err = os.Create(uri); err != nil {
+		return
+	}
+	defer f.Close()
+
+	buf := bufio.NewWriter(f)
+	for _, line := range lines {
+		if _, err = buf.WriteString(line + "\n"); err != nil {
+			return
+		}
+	}
+
+	return buf.Flush()
+}
+
+func updateApplications(applications []MirrorApplication) func(*gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if len(applications) == 0 {
+			return nil
+		}
+
+		return tx.Save(&applications).Error
+	}
+}
+
 func updateMirrors(countries []Country) func(*gorm.DB) error {
 	return func(tx *gorm.DB) error {
 		if len(countries) == 0 {
@@ -246,3 +586,65 @@ func updateMirrors(countries []Country) func(*gorm.DB) error {
 		return tx.Create(&countries).Error
 	}
 }
+
+func mirrorStatus(m Mirror) string {
+	if m.Online {
+		return "online"
+	}
+
+	return "offline"
+}
+
+func syncStatus(r Repo) string {
+	if r.Sync {
+		return "synced"
+	}
+
+	return "not synced"
+}
+
+// mirrorChanges returns the description of the changes of status
+// between the mirrors of the database and the checked mirrors.
+func mirrorChanges(oldMirrors []Mirror, countries []Country) (changes []string) {
+	if len(countries) == 0 {
+		return
+	}
+
+	old := make(map[string]Mirror)
+	for _, m := range oldMirrors {
+		old[m.Name] = m
+	}
+
+	for _, c := range countries {
+		for _, m := range c.Mirrors {
+			om, ok := old[m.Name]
+			if !ok {
+				changes = append(changes, fmt.Sprintf("%s: new mirror (%s)", m.Name, mirrorStatus(m)))
+				continue
+			}
+			delete(old, m.Name)
+
+			if om.Online != m.Online {
+				changes = append(changes, fmt.Sprintf("%s: %s → %s", m.Name, mirrorStatus(om), mirrorStatus(m)))
+			}
+
+			repos := make(map[string]Repo)
+			for _, r := range om.Repos {
+				repos[r.Name+"/"+r.Arch] = r
+			}
+			for _, r := range m.Repos {
+				k := r.Name + "/" + r.Arch
+				if or, ok := repos[k]; !ok || or.Sync != r.Sync {
+					changes = append(changes, fmt.Sprintf("%s: %s %s → %s", m.Name, k, syncStatus(or), syncStatus(r)))
+				}
+			}
+		}
+	}
+
+	for name := range old {
+		changes = append(changes, fmt.Sprintf("%s: removed", name))
+	}
+	sort.Strings(changes)
+
+	return
+}
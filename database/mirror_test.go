@@ -0,0 +1,117 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMirrorName(t *testing.T) {
+	tests := []struct {
+		server, want string
+	}{
+		{"http://mirror.example.net/kaos/$repo", "http://mirror.example.net/kaos/"},
+		{"http://mirror.example.net/kaos/$repo/os/$arch", "http://mirror.example.net/kaos/"},
+		{"http://mirror.example.net/$arch/kaos/$repo", "http://mirror.example.net/"},
+		{"http://mirror.example.net/kaos/", "http://mirror.example.net/kaos/"},
+	}
+
+	for _, tt := range tests {
+		if got := MirrorName(tt.server); got != tt.want {
+			t.Errorf("MirrorName(%q) = %q, want %q", tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestNewMirrorArchs(t *testing.T) {
+	m := newMirror("http://mirror.example.net/$arch/$repo", []string{"core", "main"}, []string{"x86_64", "aarch64"})
+
+	if m.Name != "http://mirror.example.net/" {
+		t.Errorf("Name = %q, want %q", m.Name, "http://mirror.example.net/")
+	}
+	if len(m.Repos) != 4 {
+		t.Fatalf("got %d repos, want 4", len(m.Repos))
+	}
+	if want := "http://mirror.example.net/aarch64/main/"; m.Repos[3].url != want {
+		t.Errorf("url = %q, want %q", m.Repos[3].url, want)
+	}
+}
+
+func TestReportMirror(t *testing.T) {
+	tests := []struct {
+		name     string
+		mirror   Mirror
+		warnings int
+	}{
+		{"online", Mirror{Name: "http://a/", Online: true, Repos: []Repo{{Name: "core", Arch: "x86_64", Sync: true}}}, 0},
+		{"offline", Mirror{Name: "http://b/", Error: "connection refused"}, 1},
+		{"failed repo", Mirror{Name: "http://c/", Online: true, Repos: []Repo{{Name: "core", Arch: "x86_64", Error: "[404] 404 Not Found"}}}, 1},
+		{"capability", Mirror{Name: "http://d/", Online: true, CapabilityError: "redirect: timeout"}, 1},
+	}
+
+	for _, tt := range tests {
+		res := newUpdateResult()
+		reportMirror(res, tt.mirror)
+
+		// The availability of the mirrors never fails the update.
+		if got := res.Status(); got != UpdateSuccess {
+			t.Errorf("%s: got status %s, want %s", tt.name, got, UpdateSuccess)
+		}
+		if got := len(res.Map()["warnings"].([]UpdateIssue)); got != tt.warnings {
+			t.Errorf("%s: got %d warnings, want %d", tt.name, got, tt.warnings)
+		}
+	}
+}
+
+func TestMirrorChanges(t *testing.T) {
+	repos := func(sync ...bool) []Repo {
+		out := make([]Repo, len(sync))
+		for i, s := range sync {
+			out[i] = Repo{Name: []string{"core", "main"}[i], Arch: "x86_64", Sync: s}
+		}
+		return out
+	}
+	oldMirrors := []Mirror{
+		{Name: "http://a/", Online: true, Repos: repos(true, true)},
+		{Name: "http://b/", Online: true, Repos: repos(true, true)},
+		{Name: "http://c/", Online: true, Repos: repos(true, false)},
+		{Name: "http://d/", Online: true, Repos: repos(true)},
+	}
+
+	tests := []struct {
+		name    string
+		mirrors []Mirror
+		want    []string
+	}{
+		{"unchanged", []Mirror{oldMirrors[0], oldMirrors[1], oldMirrors[2], oldMirrors[3]}, nil},
+		{"new", []Mirror{oldMirrors[0], oldMirrors[1], oldMirrors[2], oldMirrors[3], {Name: "http://e/"}}, []string{
+			"http://e/: new mirror (offline)",
+		}},
+		{"removed", []Mirror{oldMirrors[0], oldMirrors[2], oldMirrors[3]}, []string{
+			"http://b/: removed",
+		}},
+		{"offline", []Mirror{oldMirrors[0], {Name: "http://b/", Repos: repos(true, true)}, oldMirrors[2], oldMirrors[3]}, []string{
+			"http://b/: online → offline",
+		}},
+		{"sync", []Mirror{
+			oldMirrors[0],
+			oldMirrors[1],
+			{Name: "http://c/", Online: true, Repos: repos(false, true)},
+			{Name: "http://d/", Online: true, Repos: repos(true, true)},
+		}, []string{
+			"http://c/: core/x86_64 synced → not synced",
+			"http://c/: main/x86_64 not synced → synced",
+			"http://d/: main/x86_64 not synced → synced",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := mirrorChanges(oldMirrors, []Country{{Name: "Somewhere", Mirrors: tt.mirrors}})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mirrorChanges() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := mirrorChanges(oldMirrors, nil); got != nil {
+		t.Errorf("without checked mirrors: mirrorChanges() = %q, want none", got)
+	}
+}
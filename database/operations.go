@@ -1,8 +1,11 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"pmanager/log"
+	"sort"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -23,84 +26,365 @@ func prScope(pr []string) []func(*gorm.DB) *gorm.DB {
 	return out
 }
 
-func UpdateMirrors(pacmanConf, pacmanMirrors, mainMirrorName string) map[string]int {
-	countries, err := searchMirrorUpdate(pacmanConf, pacmanMirrors, mainMirrorName)
+func countMirrors(countries []Country) (c, m, e, x int) {
+	c = len(countries)
+	for _, country := range countries {
+		m += len(country.Mirrors)
+		for _, mirror := range country.Mirrors {
+			if mirror.Error != "" {
+				e++
+			}
+			if certificateExpiresSoon(mirror) {
+				x++
+			}
+			for _, repo := range mirror.Repos {
+				if repo.Error != "" {
+					e++
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func findPendingApplications() (applications []MirrorApplication) {
+	Search(&applications, NewFilterRequest(NewFilter("status", "=", ApplicationPending)))
+
+	return
+}
+
+// errDryRun cancels the transaction of a dry run.
+var errDryRun = errors.New("Dry run")
+
+// transaction executes the function in a transaction
+// which is rolled back if dryRun is set.
+func transaction(dryRun bool, f func(*gorm.DB) error) error {
+	err := dbsingleton.Transaction(func(tx *gorm.DB) error {
+		if err := f(tx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if err == errDryRun {
+		return nil
+	}
+
+	return err
+}
+
+func findAllMirrors() (mirrors []Mirror) {
+	SearchAll(&mirrors, "Repos")
+
+	return
+}
+
+func mirrorsResult(res *UpdateResult, countries []Country, applications []MirrorApplication) {
+	c, m, e, x := countMirrors(countries)
+
+	res.Set("countries", c)
+	res.Set("mirrors", m)
+	res.Set("mirror_errors", e)
+	res.Set("certificates_expiring", x)
+	res.Set("applications_checked", len(applications))
+}
+
+// UpdateMirrors checks the mirrors of the mirrorlist,
+// the approved mirrors of the managed mirrorlist
+// and the pending mirror applications.
+// If dryRun is set, the database is not modified and the result
+// lists the mirrors whose status would change.
+func UpdateMirrors(pacmanConf, pacmanMirrors, managedMirrors, mainMirrorName, geoipDb string, dryRun bool) *UpdateResult {
+	var (
+		res          = newUpdateResult()
+		begin        = time.Now()
+		applications = findPendingApplications()
+		oldMirrors   []Mirror
+	)
+	defer res.Time("total", begin)
+
+	countries, err := searchMirrorUpdate(res, pacmanConf, pacmanMirrors, managedMirrors, mainMirrorName, geoipDb, applications)
 	if err != nil {
 		log.Errorf("Failed to get mirrors: %s\n", err)
-		return nil
+		res.Fail("mirrors", err)
+		return res
+	}
+	res.Time("mirrors", begin)
+
+	if dryRun {
+		oldMirrors = findAllMirrors()
 	}
 
 	dbsingleton.Lock()
 	defer dbsingleton.Unlock()
 
-	if err = dbsingleton.Transaction(updateMirrors(countries)); err != nil {
+	err = transaction(dryRun, func(tx *gorm.DB) (err error) {
+		if err = updateMirrors(countries)(tx); err != nil {
+			return
+		}
+		return updateApplications(applications)(tx)
+	})
+
+	if err != nil {
 		log.Errorf("Failed to update mirrors database: %s\n", err)
-		return nil
+		res.Fail("database", err)
+		return res
 	}
 
-	c, m := len(countries), 0
-	for _, e := range countries {
-		m += len(e.Mirrors)
+	mirrorsResult(res, countries, applications)
+	if dryRun {
+		res.Set("dry_run", true)
+		res.Set("mirrors_changed", mirrorChanges(oldMirrors, countries))
 	}
 
-	return map[string]int{
-		"countries": c,
-		"mirrors":   m,
+	return res
+}
+
+// packagesUpdate is the list of the changes
+// to apply on the packages of the database.
+type packagesUpdate struct {
+	add, update, remove []Package
+	removeFlags         []Flag
+	unchanged           int
+	states              []RepoState
+	scanned, skipped    []string
+	repos               []string
+}
+
+// searchPackagesChanges scans the repositories which changed
+// since the last update (or all if force is set)
+// and compares their packages with the database.
+// If repos is not empty, only these repositories are updated.
+// The problems of the repositories are recorded in the result.
+func searchPackagesChanges(
+	res *UpdateResult,
+	base,
+	extension string,
+	includes,
+	excludes,
+	archs,
+	repos []string,
+	force bool,
+) (u packagesUpdate, err error) {
+	begin := time.Now()
+	defer res.Time("repositories", begin)
+
+	incl := selectIncludes(getIncludes(includes, excludes), repos)
+	packages, scans, err := searchPackageUpdate(base, extension, incl, archs, findRepoStates(), force)
+	if err != nil {
+		return
 	}
+
+	u.repos = repos
+	skipped, failed := make(map[string]bool), make(map[string]bool)
+	for _, sc := range scans {
+		target := "repo " + sc.String()
+		if sc.skipped {
+			skipped[sc.String()] = true
+			u.skipped = append(u.skipped, sc.String())
+		} else {
+			u.scanned = append(u.scanned, sc.String())
+			res.setTiming(target, sc.duration)
+		}
+		// A package whose entry can't be parsed would be removed,
+		// so the repository is kept as it is until its database is fixed.
+		for _, e := range sc.entryErrors {
+			res.Error(target, fmt.Sprintf("Failed to parse an entry, update aborted: %s", e))
+		}
+		if len(sc.entryErrors) > 0 {
+			failed[sc.String()] = true
+			log.Errorf("Failed to parse %d entries of the database of %s, its update is aborted\n", len(sc.entryErrors), sc)
+		}
+		if sc.err != nil {
+			failed[sc.String()] = true
+			log.Errorf("Failed to read the database of %s, its update is aborted: %s\n", sc, sc.err)
+			res.Error(target, fmt.Sprintf("Failed to read the database, update aborted: %s", sc.err))
+		}
+		if !failed[sc.String()] && sc.state.Hash != "" {
+			u.states = append(u.states, sc.state)
+		}
+	}
+
+	var (
+		oldPackages = findAllPackages()
+		da          = defaultArch(archs)
+		refused     = make(map[string]string)
+	)
+
+	keep := func(repo, arch string) bool {
+		k := repo + "/" + arch
+		_, r := refused[k]
+		return skipped[k] || failed[k] || r || (len(repos) > 0 && !incl[repo])
+	}
+
+	// The packages of the repositories which failed to be read are ignored,
+	// as their databases may have been partially read.
+	packages = excludePackages(packages, failed)
+	u.add, u.update, u.remove, u.removeFlags, u.unchanged = unzipPackages(oldPackages, packages, da, keep)
+
+	if !force {
+		if refused = checkRemovals(oldPackages, u.remove, da, repoMaxRemoval); len(refused) > 0 {
+			excluded := make(map[string]bool)
+			for k, reason := range refused {
+				excluded[k] = true
+				log.Warnf("Update of %s refused (use --force to apply it): %s\n", k, reason)
+				res.Error("repo "+k, fmt.Sprintf("Update refused (use --force to apply it): %s", reason))
+			}
+
+			packages = excludePackages(packages, excluded)
+			u.add, u.update, u.remove, u.removeFlags, u.unchanged = unzipPackages(oldPackages, packages, da, keep)
+
+			states := u.states[:0]
+			for _, st := range u.states {
+				if !excluded[st.key()] {
+					states = append(states, st)
+				}
+			}
+			u.states = states
+		}
+	}
+
+	log.Debugln("add:", len(u.add), "; update:", len(u.update), "; remove:", len(u.remove), "; unchanged:", u.unchanged)
+
+	if signatureAtUpdate {
+		verifyUpdate(res, base, &u)
+	}
+
+	return
 }
 
-func UpdatePackages(base, extension string, includes, excludes []string) map[string]int {
-	packages := searchPackageUpdate(base, extension, getIncludes(includes, excludes))
+func (u packagesUpdate) apply(tx *gorm.DB) error {
+	if err := updatePackages(u.add, u.update, u.remove, u.removeFlags)(tx); err != nil {
+		return err
+	}
 
-	oldPackages := findAllPackages()
-	add, update, remove, removeFlags := unzipPackages(oldPackages, packages)
-	log.Debugln("add:", len(add), "; update:", len(update), "; remove:", len(remove))
+	return updateRepoStates(u.states, u.repos)(tx)
+}
+
+func packageNames(packages []Package) []string {
+	names := make([]string, len(packages))
+	for i, p := range packages {
+		names[i] = archRepoName(p.Repository, p.RepoArch, p.VersionName())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func flagNames(flags []Flag) []string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = f.FullName()
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// details adds the list of the changes to the result.
+func (u packagesUpdate) details(res *UpdateResult) {
+	res.Set("dry_run", true)
+	res.Set("packages_to_add", packageNames(u.add))
+	res.Set("packages_to_update", packageNames(u.update))
+	res.Set("packages_to_remove", packageNames(u.remove))
+	res.Set("flags_to_remove", flagNames(u.removeFlags))
+}
+
+func (u packagesUpdate) result(res *UpdateResult) {
+	res.Set("packages_added", len(u.add))
+	res.Set("packages_updated", len(u.update))
+	res.Set("packages_removed", len(u.remove))
+	res.Set("packages_unchanged", u.unchanged)
+	res.Set("flags_removed", len(u.removeFlags))
+	res.Set("repos_scanned", u.scanned)
+	res.Set("repos_skipped", u.skipped)
+}
+
+// UpdatePackages updates the packages of the repositories
+// (or only of the given repos if not empty).
+// Unless force is set, only the repositories whose database
+// changed since the last update are scanned.
+// If dryRun is set, the database is not modified and the result
+// lists the packages and flags which would be changed.
+func UpdatePackages(base, extension string, includes, excludes, archs, repos []string, force, dryRun bool) *UpdateResult {
+	res := newUpdateResult()
+	defer res.Time("total", time.Now())
+
+	u, err := searchPackagesChanges(res, base, extension, includes, excludes, archs, repos, force)
+	if err != nil {
+		log.Errorf("Failed to get packages: %s\n", err)
+		res.Fail("repositories", err)
+		return res
+	}
 
 	dbsingleton.Lock()
 	defer dbsingleton.Unlock()
 
-	if err := dbsingleton.Transaction(updatePackages(add, update, remove, removeFlags)); err != nil {
+	if err := transaction(dryRun, u.apply); err != nil {
 		log.Errorf("Failed to update packages database: %s\n", err)
-		return nil
+		res.Fail("database", err)
+		return res
 	}
 
-	return map[string]int{
-		"packages_added":   len(add),
-		"packages_updated": len(update),
-		"packages_removed": len(remove),
-		"flags_removed":    len(removeFlags),
+	u.result(res)
+	if dryRun {
+		u.details(res)
 	}
+
+	return res
 }
 
+// UpdateAll updates the mirrors and the packages in the same transaction.
+// If the mirrors or the packages can't be read, the other part is still updated.
 func UpdateAll(
 	pacmanConf,
 	pacmanMirrors,
+	managedMirrors,
 	mainMirrorName,
+	geoipDb,
 	base,
 	extension string,
 	includes,
-	excludes []string,
-) map[string]int {
+	excludes,
+	archs []string,
+	force,
+	dryRun bool,
+) *UpdateResult {
 	var (
+		res                 = newUpdateResult()
 		done                = make(chan bool, 2)
-		err                 error
+		errMirrors, errPkgs error
 		countries           []Country
-		add, update, remove []Package
-		removeFlags         []Flag
+		applications        = findPendingApplications()
+		u                   packagesUpdate
+		oldMirrors          []Mirror
 	)
+	defer res.Time("total", time.Now())
+
+	if dryRun {
+		oldMirrors = findAllMirrors()
+	}
 
 	go func() {
-		if countries, err = searchMirrorUpdate(pacmanConf, pacmanMirrors, mainMirrorName); err != nil {
-			log.Errorf("Failed to get mirrors: %s\n", err)
+		begin := time.Now()
+		if countries, errMirrors = searchMirrorUpdate(res, pacmanConf, pacmanMirrors, managedMirrors, mainMirrorName, geoipDb, applications); errMirrors != nil {
+			log.Errorf("Failed to get mirrors: %s\n", errMirrors)
+			res.Error("mirrors", errMirrors)
+			applications = nil
 		}
+		res.Time("mirrors", begin)
 		done <- true
 	}()
 
 	go func() {
-		packages := searchPackageUpdate(base, extension, getIncludes(includes, excludes))
-		oldPackages := findAllPackages()
-		add, update, remove, removeFlags = unzipPackages(oldPackages, packages)
-
+		if u, errPkgs = searchPackagesChanges(res, base, extension, includes, excludes, archs, nil, force); errPkgs != nil {
+			log.Errorf("Failed to get packages: %s\n", errPkgs)
+			res.Error("repositories", errPkgs)
+		}
 		done <- true
 	}()
 
@@ -108,33 +392,104 @@ func UpdateAll(
 		<-done
 	}
 
+	if errMirrors != nil && errPkgs != nil {
+		res.Fail("update", "Nothing to update")
+		return res
+	}
+
 	dbsingleton.Lock()
 	defer dbsingleton.Unlock()
 
-	err = dbsingleton.Transaction(func(tx *gorm.DB) (err error) {
+	err := transaction(dryRun, func(tx *gorm.DB) (err error) {
 		if err = updateMirrors(countries)(tx); err != nil {
 			return
 		}
-		return updatePackages(add, update, remove, removeFlags)(tx)
+		if err = updateApplications(applications)(tx); err != nil {
+			return
+		}
+		if errPkgs != nil {
+			return
+		}
+		return u.apply(tx)
 	})
 
 	if err != nil {
-		log.Fatalf("Failed to update database: %s\n", err)
+		log.Errorf("Failed to update database: %s\n", err)
+		res.Fail("database", err)
+		return res
+	}
+
+	mirrorsResult(res, countries, applications)
+	u.result(res)
+	if dryRun {
+		u.details(res)
+		res.Set("mirrors_changed", mirrorChanges(oldMirrors, countries))
+	}
+
+	return res
+}
+
+func CreateMirrorApplication(a *MirrorApplication) error {
+	dbsingleton.Lock()
+	defer dbsingleton.Unlock()
+
+	return dbsingleton.Create(a).Error
+}
+
+// ApproveMirrorApplications approves the validated applications
+// and adds their mirrors to the given mirrorlist.
+// It returns the number of approved applications.
+func ApproveMirrorApplications(ids []uint, mirrorlist string) (int, error) {
+	var applications, approved []MirrorApplication
+	f := NewFilter("id", "IN", ids)
+
+	if !Search(&applications, NewFilterRequest(f, NewFilter("status", "=", ApplicationPending))) {
+		return 0, nil
+	}
+
+	for _, a := range applications {
+		if a.IsValidated() {
+			a.Status = ApplicationApproved
+			approved = append(approved, a)
+		} else {
+			log.Warnf("Mirror application %s is not validated yet\n", a.URL)
+		}
 	}
 
-	c, m := len(countries), 0
-	for _, e := range countries {
-		m += len(e.Mirrors)
+	if len(approved) == 0 {
+		return 0, nil
 	}
 
-	return map[string]int{
-		"countries":        c,
-		"mirrors":          m,
-		"packages_added":   len(add),
-		"packages_updated": len(update),
-		"packages_removed": len(remove),
-		"flags_removed":    len(removeFlags),
+	if err := addToMirrorList(mirrorlist, approved); err != nil {
+		return 0, err
 	}
+
+	dbsingleton.Lock()
+	defer dbsingleton.Unlock()
+
+	if err := dbsingleton.Transaction(updateApplications(approved)); err != nil {
+		return 0, err
+	}
+
+	return len(approved), nil
+}
+
+// RejectMirrorApplications rejects the pending applications
+// and returns the number of rejected applications.
+func RejectMirrorApplications(ids []uint) int {
+	dbsingleton.Lock()
+	defer dbsingleton.Unlock()
+
+	result := dbsingleton.
+		Model(&MirrorApplication{}).
+		Where("id IN ? AND status = ?", ids, ApplicationPending).
+		Update("status", ApplicationRejected)
+	if result.Error != nil {
+		log.Errorf("Failed to reject mirror applications: %s\n", result.Error)
+		return 0
+	}
+
+	return int(result.RowsAffected)
 }
 
 func First(e any, r *Request, preload ...string) bool {
@@ -206,10 +561,14 @@ func GetPackage(p *Package, r *Request, base string) (ok bool) {
 
 	if p.FlagID == 0 && p.Repository != "build" {
 		pb := new(Package)
-		if First(pb, NewFilterRequest(
+		q := NewFilterRequest(
 			NewFilter("repository", "=", "build"),
 			NewFilter("name", "=", p.Name),
-		)) {
+		)
+		if p.RepoArch != "" {
+			q.AddFilter("repo_arch", "=", p.RepoArch)
+		}
+		if First(pb, q) {
 			p.BuildVersion = pb
 		}
 	}
@@ -227,11 +586,45 @@ func GetPackage(p *Package, r *Request, base string) (ok bool) {
 	return
 }
 
-func CreateFlag(p *Package) error {
+// CreateFlags flags all the packages with a copy of the same flag
+// (same submitter and comment).
+func CreateFlags(packages []Package, f Flag) error {
 	dbsingleton.Lock()
 	defer dbsingleton.Unlock()
 
-	return dbsingleton.Transaction(createFlag(p))
+	return dbsingleton.Transaction(func(tx *gorm.DB) error {
+		for i := range packages {
+			p := &packages[i]
+			p.Flag = f
+			p.Flag.Name, p.Flag.Version = p.Name, p.Version
+			p.Flag.Repository, p.Flag.Arch = p.Repository, p.RepoArch
+			if err := createFlag(p)(tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// FindPkgBase returns the packages of the repository
+// built from the given pkgbase, sorted by name.
+// If arch is not empty, only the packages of this architecture are returned.
+func FindPkgBase(repo, arch, base string) (packages []Package) {
+	q := NewRequest(
+		[]Filter{
+			NewFilter("repository", "=", repo),
+			NewFilter("COALESCE(NULLIF(base, ''), name)", "=", base),
+		},
+		[]Sort{NewSort("name", false)},
+	)
+	if arch != "" {
+		q.AddFilter("repo_arch", "=", arch)
+	}
+
+	Search(&packages, q, "Flag")
+
+	return
 }
 
 func DeleteFlags(ids []uint) int {
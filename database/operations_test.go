@@ -0,0 +1,78 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+type dbSnapshot struct {
+	packages map[string]string // name → version
+	flags    []string
+	states   map[string]string // repo → hash
+}
+
+func takeSnapshot() (s dbSnapshot) {
+	s.packages, s.states = make(map[string]string), make(map[string]string)
+
+	var packages []Package
+	dbsingleton.Find(&packages)
+	for _, p := range packages {
+		s.packages[p.Name] = p.Version
+	}
+	var flags []Flag
+	dbsingleton.Find(&flags)
+	s.flags = flagNames(flags)
+	for k, st := range findRepoStates() {
+		s.states[k] = st.Hash
+	}
+
+	return
+}
+
+func TestUpdatePackagesDryRun(t *testing.T) {
+	archs := []string{"x86_64"}
+	base := t.TempDir()
+	writeTestRepo(t, base, "core", 12, 2)
+	loadTestDb(t)
+
+	if res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, archs, nil, false, false); res.Status() != UpdateSuccess {
+		t.Fatalf("update %s: %v", res.Status(), res.Map())
+	}
+
+	// pkg00001 is outdated and flagged, pkg00002 is missing
+	// and pkg00010 and pkg00011 are removed from the repository.
+	dbsingleton.Model(&Package{}).Where("name = ?", "pkg00001").Update("version", "0.9-1")
+	dbsingleton.Unscoped().Where("name = ?", "pkg00002").Delete(&Package{})
+	var flagged []Package
+	dbsingleton.Where("name = ?", "pkg00001").Find(&flagged)
+	if err := CreateFlags(flagged, Flag{Email: "user@example.net", Comment: "outdated"}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestRepo(t, base, "core", 10, 2)
+
+	before := takeSnapshot()
+	res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, archs, nil, false, true)
+	if res.Status() != UpdateSuccess {
+		t.Fatalf("dry run %s: %v", res.Status(), res.Map())
+	}
+
+	data := res.Map()
+	want := map[string][]string{
+		"packages_to_add":    {"core/x86_64/pkg00002-1.0-1"},
+		"packages_to_update": {"core/x86_64/pkg00001-1.0-1"},
+		"packages_to_remove": {"core/x86_64/pkg00010-1.0-1", "core/x86_64/pkg00011-1.0-1"},
+		"flags_to_remove":    {"core/pkg00001-0.9-1"},
+	}
+	for k, w := range want {
+		if got := data[k]; !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %v, want %v", k, got, w)
+		}
+	}
+	if data["dry_run"] != true {
+		t.Errorf("dry_run = %v, want true", data["dry_run"])
+	}
+
+	if after := takeSnapshot(); !reflect.DeepEqual(after, before) {
+		t.Errorf("the dry run changed the database:\nbefore %+v\nafter  %+v", before, after)
+	}
+}
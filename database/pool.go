@@ -0,0 +1,35 @@
+package database
+
+import (
+	"sync"
+)
+
+// runTasks executes the tasks with a pool of workers
+// and waits for them to finish.
+func runTasks(workers int, tasks []func()) {
+	var (
+		queue = make(chan func())
+		wg    sync.WaitGroup
+	)
+
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				task()
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+
+	wg.Wait()
+}
@@ -2,15 +2,17 @@ package database
 
 import (
 	"bufio"
-	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
 	"strings"
-	"sync"
+	"time"
 
 	"pmanager/log"
 	"pmanager/util/conv"
+	"pmanager/util/pacman"
 	"pmanager/util/resource"
 
 	"gorm.io/gorm"
@@ -18,8 +20,33 @@ import (
 
 const (
 	bufferSize = 100
+
+	// Maximal length of a line in the entries of a repository database.
+	maxLineSize = 1 << 20
+)
+
+var (
+	// Number of repositories databases parsed concurrently.
+	repoWorkers = 4
+
+	// Maximal percentage of the packages of a repository
+	// which can be removed by an update (0 for no limit).
+	repoMaxRemoval = 50
 )
 
+// InitRepoUpdate configures the update of the repositories.
+// - workers : number of repositories databases parsed concurrently
+// - maxRemoval : maximal percentage of the packages of a repository which can be removed by an update without --force (0 for no limit)
+func InitRepoUpdate(workers, maxRemoval int64) {
+	if workers <= 0 {
+		workers = 1
+	}
+	if maxRemoval < 0 {
+		maxRemoval = 0
+	}
+	repoWorkers, repoMaxRemoval = int(workers), int(maxRemoval)
+}
+
 type packageFiles struct {
 	name  string
 	files []string
@@ -39,11 +66,43 @@ func getIncludes(includes, excludes []string) map[string]bool {
 	return m
 }
 
-func getRepoFilePath(base, repoName, extension string) string {
-	return path.Join(base, repoName, repoName+"."+extension)
+// selectIncludes restricts the included repositories to the given repos.
+// If repos is empty, all the included repositories are kept.
+func selectIncludes(incl map[string]bool, repos []string) map[string]bool {
+	if len(repos) == 0 {
+		return incl
+	}
+
+	m := make(map[string]bool)
+	for _, r := range repos {
+		if incl[r] {
+			m[r] = true
+		} else {
+			log.Warnf("Repo %s is not included in the configuration, ignored\n", r)
+		}
+	}
+
+	return m
+}
+
+// repoDir is the directory of a repository for an architecture.
+// It is either <base>/<repo>/<arch> or <base>/<repo>
+// if the repository doesn’t have architecture subfolders.
+type repoDir struct {
+	name string
+	arch string
+	path string
 }
 
-func getRepoNames(base string, incl map[string]bool) (repo []string, err error) {
+func (rd repoDir) String() string {
+	return rd.name + "/" + rd.arch
+}
+
+func getRepoFilePath(base string, rd repoDir, extension string) string {
+	return path.Join(base, rd.path, rd.name+"."+extension)
+}
+
+func getRepoDirs(base string, incl map[string]bool, archs []string) (repos []repoDir, err error) {
 	files, err := os.ReadDir(base)
 	if err != nil {
 		return
@@ -51,14 +110,34 @@ func getRepoNames(base string, incl map[string]bool) (repo []string, err error)
 
 	for _, f := range files {
 		fn := f.Name()
-		if f.IsDir() && incl[fn] {
-			repo = append(repo, fn)
+		if !f.IsDir() || !incl[fn] {
+			continue
+		}
+
+		found := false
+		for _, arch := range archs {
+			if resource.IsDir(path.Join(base, fn, arch)) {
+				found = true
+				repos = append(repos, repoDir{name: fn, arch: arch, path: path.Join(fn, arch)})
+			}
+		}
+
+		if !found {
+			repos = append(repos, repoDir{name: fn, arch: defaultArch(archs), path: fn})
 		}
 	}
 
 	return
 }
 
+func defaultArch(archs []string) string {
+	if len(archs) > 0 {
+		return archs[0]
+	}
+
+	return pacman.Arch()
+}
+
 func scanPkginfo(sc *bufio.Scanner, git *Git) {
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
@@ -71,17 +150,12 @@ func scanPkginfo(sc *bufio.Scanner, git *Git) {
 	}
 }
 
-func scanDesc(sc *bufio.Scanner, repo, name string, packages chan Package) {
-	var (
-		p       Package
-		section string
-	)
-
-	defer func() {
-		packages <- p
-	}()
+// scanDesc parses the desc entry of a package.
+func scanDesc(sc *bufio.Scanner, rd repoDir, name string) (p Package, err error) {
+	var section string
 
-	p.Name, p.Repository = name, repo
+	p.Name, p.Repository = name, rd.name
+	p.RepoArch, p.RepoPath = rd.arch, rd.path
 
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
@@ -123,27 +197,35 @@ func scanDesc(sc *bufio.Scanner, repo, name string, packages chan Package) {
 			p.MakeDepends = append(p.MakeDepends, line)
 		case "OPTDEPENDS":
 			p.OptDepends = append(p.OptDepends, line)
+		case "PROVIDES":
+			p.Provides = append(p.Provides, line)
 		case "MD5SUM":
 			p.Md5Sum = line
 		case "SHA256SUM":
 			p.Sha256Sum = line
 		case "FILENAME":
 			p.Filename = line
+		case "BASE":
+			p.Base = line
+		case "PACKAGER":
+			p.Packager = line
+		case "PGPSIG":
+			p.PgpSig = line
 		}
 	}
-}
 
-func scanFiles(sc *bufio.Scanner, repo, name string, files chan packageFiles) {
-	var (
-		f       packageFiles
-		section string
-	)
+	if err = sc.Err(); err == nil && p.Version == "" {
+		err = errors.New("Missing %VERSION% section")
+	}
 
-	defer func() {
-		files <- f
-	}()
+	return
+}
 
-	f.name = repoName(repo, name)
+// scanFiles parses the files entry of a package.
+func scanFiles(sc *bufio.Scanner, rd repoDir, name string) (f packageFiles, err error) {
+	var section string
+
+	f.name = path.Join(rd.path, name)
 
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
@@ -161,54 +243,76 @@ func scanFiles(sc *bufio.Scanner, repo, name string, files chan packageFiles) {
 			f.files = append(f.files, line)
 		}
 	}
+
+	err = sc.Err()
+
+	return
 }
 
-func scanDescOrFiles(
+// scanEntry parses an entry (desc or files) of the database of a repository.
+func scanEntry(
 	content io.Reader,
-	repo, file, suffix string,
+	rd repoDir,
+	file, suffix string,
 	packages chan Package,
 	files chan packageFiles,
-	wg *sync.WaitGroup,
-) {
-	defer wg.Done()
-
-	name := path.Base(strings.TrimSuffix(file, suffix))
+) error {
+	name := path.Base(strings.TrimSuffix(file, "/"+suffix))
 	sc := bufio.NewScanner(content)
+	sc.Buffer(nil, maxLineSize)
 
 	switch suffix {
 	case "desc":
-		scanDesc(sc, repo, name, packages)
+		p, err := scanDesc(sc, rd, name)
+		if err != nil {
+			return err
+		}
+		packages <- p
 	case "files":
-		scanFiles(sc, repo, name, files)
+		f, err := scanFiles(sc, rd, name)
+		if err != nil {
+			return err
+		}
+		files <- f
 	}
+
+	return nil
 }
 
+// readRepoDb parses the entries of the database of a repository
+// as they are read from the (compressed) tar stream.
+// The errors of the entries are recorded in the scan
+// and don't stop the parsing of the other entries.
 func readRepoDb(
-	base, repo, extension string,
+	base string,
+	sc *repoScan,
+	extension string,
 	desc chan Package,
 	files chan packageFiles,
-	wg *sync.WaitGroup,
 ) {
-	defer wg.Done()
+	begin := time.Now()
+	defer func() { sc.duration = time.Since(begin) }()
 
 	var (
-		filePath = getRepoFilePath(base, repo, extension)
+		rd       = sc.repoDir
+		filePath = getRepoFilePath(base, rd, extension)
 		tf, err  = resource.OpenArchive(filePath)
 	)
 
 	log.Debugf("Extracting %s\n", filePath)
 	if err != nil {
+		sc.err = err
 		log.Debugf("\033[1;31mFailed to extract %s: %s\033[m\n", filePath, err)
 		return
 	}
-
-	var wg2 sync.WaitGroup
+	defer tf.Close()
 
 	for {
 		hdr, err := tf.Next()
 
 		if err != nil {
 			if err != io.EOF {
+				sc.err = err
 				log.Debugf("\033[1;31mFailed to parse %s: %s\n\033[m", filePath, err)
 			}
 			break
@@ -223,23 +327,26 @@ func readRepoDb(
 			continue
 		}
 
-		var buf bytes.Buffer
-		if _, err = io.Copy(&buf, tf); err != nil {
-			log.Debugf("\033[1;31mFailed to read %s in %s: %s\n\033[m", hdr.Name, filePath, err)
-			continue
+		if err = scanEntry(tf, rd, hdr.Name, suffix, desc, files); err != nil {
+			sc.entryErrors = append(sc.entryErrors, fmt.Sprintf("%s: %s", hdr.Name, err))
+			log.Debugf("\033[1;31mFailed to parse %s in %s: %s\n\033[m", hdr.Name, filePath, err)
 		}
-
-		wg2.Add(1)
-		go scanDescOrFiles(&buf, repo, hdr.Name, suffix, desc, files, &wg2)
 	}
-
-	wg2.Wait()
 }
 
-func searchPackageUpdate(base, extension string, incl map[string]bool) (packages []Package) {
-	repos, err := getRepoNames(base, incl)
-	if err != nil {
-		log.Fatalln(err)
+// searchPackageUpdate reads the packages of the repositories
+// whose database changed since the last scan.
+func searchPackageUpdate(
+	base,
+	extension string,
+	incl map[string]bool,
+	archs []string,
+	states map[string]RepoState,
+	force bool,
+) (packages []Package, scans []*repoScan, err error) {
+	var repos []repoDir
+	if repos, err = getRepoDirs(base, incl, archs); err != nil {
+		return
 	}
 
 	var (
@@ -247,7 +354,6 @@ func searchPackageUpdate(base, extension string, incl map[string]bool) (packages
 		files  = make(chan packageFiles, bufferSize>>1)
 		mfiles = make(map[string][]string)
 		done   = make(chan bool, 3)
-		wg     sync.WaitGroup
 	)
 
 	go func() {
@@ -263,13 +369,19 @@ func searchPackageUpdate(base, extension string, incl map[string]bool) (packages
 		done <- true
 	}()
 
-	wg.Add(len(repos))
-	for _, repo := range repos {
-		go readRepoDb(base, repo, extension, desc, files, &wg)
+	var tasks []func()
+	for _, rd := range repos {
+		sc := newRepoScan(base, extension, rd, states, force)
+		scans = append(scans, sc)
+		if sc.skipped {
+			log.Debugf("\033[1;32mRepo %s is unchanged, skipped\n\033[m", rd)
+			continue
+		}
+		tasks = append(tasks, func() { readRepoDb(base, sc, extension, desc, files) })
 	}
 
 	go func() {
-		wg.Wait()
+		runTasks(repoWorkers, tasks)
 		close(desc)
 		close(files)
 		done <- true
@@ -281,7 +393,7 @@ func searchPackageUpdate(base, extension string, incl map[string]bool) (packages
 
 	for i := range packages {
 		p := &packages[i]
-		fn := p.FullName()
+		fn := path.Join(p.RepoPath, p.VersionName())
 		if f, ok := mfiles[fn]; ok {
 			p.Files = f
 		}
@@ -291,13 +403,14 @@ func searchPackageUpdate(base, extension string, incl map[string]bool) (packages
 }
 
 func searchGitInfo(base string, p *Package) bool {
-	fp := path.Join(base, p.Repository, p.Filename)
+	fp := path.Join(base, p.Dir(), p.Filename)
 
 	tf, err := resource.OpenArchive(fp)
 	if err != nil {
 		log.Debugf("\033[1;31mFailed to load %s: %s\033[m\n", fp, err)
 		return false
 	}
+	defer tf.Close()
 
 	for {
 		hdr, err := tf.Next()
@@ -305,40 +418,62 @@ func searchGitInfo(base string, p *Package) bool {
 			break
 		}
 
+		// .PKGINFO is at the beginning of the archive,
+		// so the rest of the package is never read.
 		if hdr.Name == ".PKGINFO" {
-			var buf bytes.Buffer
-			if _, err = io.Copy(&buf, tf); err == nil {
-				sc := bufio.NewScanner(&buf)
-				scanPkginfo(sc, &p.Git)
-				p.Git.Name = p.Name
-				return true
+			sc := bufio.NewScanner(tf)
+			scanPkginfo(sc, &p.Git)
+			if sc.Err() != nil {
+				break
 			}
-			break
+			p.Git.Name = p.Name
+			return true
 		}
 	}
 
 	return false
 }
 
-func unzipPackages(oldPackages, newPackages []Package) (add, update, remove []Package, removeFlags []Flag) {
+// unzipPackages compares the packages of the database with the packages
+// of the repositories. Old packages without architecture are assumed
+// to belong to the default architecture. The old packages for which keep
+// returns true (skipped or not selected repositories) are left untouched.
+// Only the packages which really changed are returned in update.
+func unzipPackages(
+	oldPackages,
+	newPackages []Package,
+	defaultArch string,
+	keep func(repo, arch string) bool,
+) (add, update, remove []Package, removeFlags []Flag, unchanged int) {
 	if len(oldPackages) == 0 {
 		add = newPackages
 		return
 	}
 
-	packages, repos := make(map[string]Package), make(map[string][]string)
+	packages, keys := make(map[string]Package), make(map[string][]string)
 	done := make(map[uint]bool)
 
+	repoArch := func(p Package) string {
+		if p.RepoArch == "" {
+			return defaultArch
+		}
+		return p.RepoArch
+	}
+
 	for _, p := range oldPackages {
-		packages[p.RepoName()] = p
-		repos[p.Name] = append(repos[p.Name], p.Repository)
+		k := archRepoName(p.Repository, repoArch(p), p.Name)
+		packages[k] = p
+		keys[p.Name] = append(keys[p.Name], k)
 	}
 
 	for _, np := range newPackages {
-		op, ok := packages[np.RepoName()]
+		op, ok := packages[np.ArchRepoName()]
 		if ok {
 			done[op.ID] = true
 			np.ID, np.CreatedAt, np.GitID, np.Git = op.ID, op.CreatedAt, op.GitID, op.Git
+			if np.Filename == op.Filename && np.Sha256Sum == op.Sha256Sum && np.PgpSig == op.PgpSig {
+				np.SignatureStatus, np.SignerFingerprint = op.SignatureStatus, op.SignerFingerprint
+			}
 
 			if np.Version == op.Version {
 				np.FlagID, np.Flag = op.FlagID, op.Flag
@@ -348,11 +483,11 @@ func unzipPackages(oldPackages, newPackages []Package) (add, update, remove []Pa
 		}
 
 		if np.GitID == 0 {
-			for _, r := range repos[np.Name] {
-				if r == np.Repository {
+			for _, k := range keys[np.Name] {
+				if k == np.ArchRepoName() {
 					continue
 				}
-				if pg := packages[repoName(r, np.Name)]; pg.GitID != 0 {
+				if pg := packages[k]; pg.GitID != 0 {
 					np.GitID, np.Git = pg.GitID, pg.Git
 					break
 				}
@@ -361,15 +496,18 @@ func unzipPackages(oldPackages, newPackages []Package) (add, update, remove []Pa
 
 		if !ok {
 			add = append(add, np)
+		} else if np.Equal(op) {
+			unchanged++
 		} else {
 			update = append(update, np)
 		}
 	}
 
 	for _, p := range oldPackages {
-		if !done[p.ID] {
+		if !done[p.ID] && !keep(p.Repository, repoArch(p)) {
 			var rp Package
 			rp.ID = p.ID
+			rp.Repository, rp.Name, rp.Version, rp.RepoArch = p.Repository, p.Name, p.Version, p.RepoArch
 			remove = append(remove, rp)
 		}
 	}
@@ -377,17 +515,75 @@ func unzipPackages(oldPackages, newPackages []Package) (add, update, remove []Pa
 	return
 }
 
+// excludePackages returns the packages
+// which don't belong to the given repositories (<repo>/<arch>).
+func excludePackages(packages []Package, repos map[string]bool) []Package {
+	if len(repos) == 0 {
+		return packages
+	}
+
+	out := make([]Package, 0, len(packages))
+	for _, p := range packages {
+		if !repos[p.Repository+"/"+p.RepoArch] {
+			out = append(out, p)
+		}
+	}
+
+	return out
+}
+
+// checkRemovals returns the repositories (<repo>/<arch>) where the update
+// would remove more than the maximal percentage of packages,
+// with the reason of the refusal.
+func checkRemovals(oldPackages, remove []Package, defaultArch string, maxRemoval int) map[string]string {
+	refused := make(map[string]string)
+	if maxRemoval <= 0 || len(remove) == 0 {
+		return refused
+	}
+
+	var (
+		repos   = make(map[uint]string)
+		total   = make(map[string]int)
+		removed = make(map[string]int)
+	)
+
+	for _, p := range oldPackages {
+		arch := p.RepoArch
+		if arch == "" {
+			arch = defaultArch
+		}
+		k := p.Repository + "/" + arch
+		repos[p.ID] = k
+		total[k]++
+	}
+
+	for _, p := range remove {
+		removed[repos[p.ID]]++
+	}
+
+	for k, r := range removed {
+		if t := total[k]; r*100 > maxRemoval*t {
+			refused[k] = fmt.Sprintf(
+				"%d of %d packages (%d%%) would be removed, more than the maximum of %d%%",
+				r, t, r*100/t, maxRemoval,
+			)
+		}
+	}
+
+	return refused
+}
+
 func updatePackages(add, update, remove []Package, removeFlags []Flag) func(*gorm.DB) error {
 	return func(tx *gorm.DB) error {
 		if len(remove) > 0 {
-			for i := 0; i < 100; i += 100 {
+			for i := 0; i < len(remove); i += 100 {
 				r := remove[i:]
 				if len(r) > 100 {
 					r = r[:100]
 				}
 				ids := make([]uint, len(r))
-				for i, p := range r {
-					ids[i] = p.ID
+				for j, p := range r {
+					ids[j] = p.ID
 				}
 				if err := tx.Unscoped().Delete(&r, ids).Error; err != nil {
 					return err
@@ -408,12 +604,13 @@ func updatePackages(add, update, remove []Package, removeFlags []Flag) func(*gor
 		}
 
 		if len(removeFlags) > 0 {
-			for i := 0; i < 100; i += 100 {
+			for i := 0; i < len(removeFlags); i += 100 {
 				r := removeFlags[i:]
 				if len(r) > 100 {
 					r = r[:100]
 				}
-				if err := tx.Unscoped().Delete(&r).Error; err != nil {
+				// Flags are soft-deleted to keep the time of their resolution.
+				if err := tx.Delete(&r).Error; err != nil {
 					return err
 				}
 			}
@@ -438,6 +635,7 @@ func updateGit(git *Git, name string) func(*gorm.DB) error {
 
 func createFlag(p *Package) func(*gorm.DB) error {
 	return func(tx *gorm.DB) error {
+		p.Flag.Packager = p.Packager
 		if err := tx.Create(&p.Flag).Error; err != nil {
 			return err
 		}
@@ -461,6 +659,7 @@ func deleteFlags(flags []Flag) func(*gorm.DB) error {
 		if err := tx.Model(&Package{}).Where("flag_id IN ?", ids).Update("flag_id", 0).Error; err != nil {
 			return err
 		}
-		return tx.Unscoped().Delete(&flags).Error
+		// Flags are soft-deleted to keep the time of their resolution.
+		return tx.Delete(&flags).Error
 	}
 }
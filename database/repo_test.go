@@ -0,0 +1,501 @@
+package database
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pmanager/log"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	log.Init("stderr")
+	os.Exit(m.Run())
+}
+
+// loadTestDb loads an empty database stored in a temporary directory.
+func loadTestDb(tb testing.TB) {
+	tb.Helper()
+
+	Load(filepath.Join(tb.TempDir(), "pmanager.db"))
+	tb.Cleanup(func() {
+		if db, err := dbsingleton.DB.DB(); err == nil {
+			db.Close()
+		}
+	})
+}
+
+func testDesc(i int) string {
+	name := fmt.Sprintf("pkg%05d", i)
+
+	return strings.Join([]string{
+		"%FILENAME%", name + "-1.0-1-x86_64.pkg.tar.zst",
+		"%NAME%", name,
+		"%BASE%", name,
+		"%VERSION%", "1.0-1",
+		"%DESC%", "Generated package " + name,
+		"%GROUPS%", "bench",
+		"%CSIZE%", "123456",
+		"%ISIZE%", "654321",
+		"%SHA256SUM%", strings.Repeat("0", 64),
+		"%URL%", "https://example.net/" + name,
+		"%LICENSE%", "GPL",
+		"%ARCH%", "x86_64",
+		"%BUILDDATE%", "1700000000",
+		"%PACKAGER%", "Bench <bench@example.net>",
+		"%DEPENDS%", "glibc", fmt.Sprintf("pkg%05d", i/2),
+		"",
+	}, "\n")
+}
+
+func testFiles(i, files int) string {
+	lines := []string{"%FILES%", "usr/", "usr/share/", fmt.Sprintf("usr/share/pkg%05d/", i)}
+	for j := 0; j < files; j++ {
+		lines = append(lines, fmt.Sprintf("usr/share/pkg%05d/file%03d", i, j))
+	}
+
+	return strings.Join(append(lines, ""), "\n")
+}
+
+// writeTestRepo writes the files database <base>/<repo>/<repo>.files.tar.gz
+// of a repository of n packages owning files files each.
+// The desc entries of the broken packages miss their version.
+func writeTestRepo(tb testing.TB, base, repo string, n, files int, broken ...int) {
+	tb.Helper()
+
+	dir := filepath.Join(base, repo)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		tb.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, repo+".files.tar.gz"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	add := func(name, content string) {
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  time.Unix(1700000000, 0),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	isBroken := make(map[int]bool)
+	for _, i := range broken {
+		isBroken[i] = true
+	}
+	for i := 0; i < n; i++ {
+		entry := fmt.Sprintf("pkg%05d-1.0-1", i)
+		desc := testDesc(i)
+		if isBroken[i] {
+			desc = strings.Replace(desc, "%VERSION%\n1.0-1\n", "", 1)
+		}
+		add(entry+"/desc", desc)
+		add(entry+"/files", testFiles(i, files))
+	}
+
+	if err := tw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestUpdatePackagesBrokenEntry(t *testing.T) {
+	base := t.TempDir()
+	writeTestRepo(t, base, "core", 10, 2)
+	loadTestDb(t)
+
+	if res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, nil, nil, false, false); res.Status() != UpdateSuccess {
+		t.Fatalf("update %s: %v", res.Status(), res.Map())
+	}
+
+	writeTestRepo(t, base, "core", 10, 2, 3)
+	res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, nil, nil, false, false)
+	if res.Status() != UpdatePartial {
+		t.Errorf("update with a broken entry: got status %s, want %s", res.Status(), UpdatePartial)
+	}
+
+	var count int64
+	dbsingleton.Model(&Package{}).Count(&count)
+	if count != 10 {
+		t.Errorf("got %d packages after the update, want the 10 packages kept", count)
+	}
+	if states := findRepoStates(); len(states) != 0 {
+		t.Errorf("got %d saved repository states, want none", len(states))
+	}
+}
+
+func countRows(tb testing.TB, model any) int64 {
+	tb.Helper()
+
+	var count int64
+	if err := dbsingleton.Model(model).Count(&count).Error; err != nil {
+		tb.Fatal(err)
+	}
+
+	return count
+}
+
+func TestUpdatePackagesMaxRemoval(t *testing.T) {
+	old := repoMaxRemoval
+	defer func() { repoMaxRemoval = old }()
+
+	archs := []string{"x86_64"}
+	tests := []struct {
+		name       string
+		maxRemoval int
+		noRepoArch bool // packages saved before the repositories had an architecture
+		force      bool
+		refused    bool
+	}{
+		{"refused", 50, false, false, true},
+		{"refused without repo arch", 50, true, false, true},
+		{"forced", 50, false, true, false},
+		{"forced without repo arch", 50, true, true, false},
+		{"no limit", 0, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoMaxRemoval = tt.maxRemoval
+			base := t.TempDir()
+			writeTestRepo(t, base, "core", 10, 2)
+			loadTestDb(t)
+
+			if res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, archs, nil, false, false); res.Status() != UpdateSuccess {
+				t.Fatalf("update %s: %v", res.Status(), res.Map())
+			}
+			if tt.noRepoArch {
+				if err := dbsingleton.Model(&Package{}).Where("1 = 1").Update("repo_arch", "").Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			var packages []Package
+			dbsingleton.Find(&packages)
+			if err := CreateFlags(packages, Flag{Email: "user@example.net", Comment: "outdated"}); err != nil {
+				t.Fatal(err)
+			}
+			states := findRepoStates()
+
+			writeTestRepo(t, base, "core", 4, 2)
+			res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, archs, nil, tt.force, false)
+			data := res.Map()
+
+			if !tt.refused {
+				if res.Status() != UpdateSuccess {
+					t.Fatalf("update %s: %v", res.Status(), data)
+				}
+				if n := data["packages_removed"]; n != 6 {
+					t.Errorf("got %v removed packages, want 6", n)
+				}
+				if n := countRows(t, &Package{}); n != 4 {
+					t.Errorf("got %d packages, want 4", n)
+				}
+				return
+			}
+
+			if res.Status() != UpdatePartial {
+				t.Errorf("got status %s, want %s", res.Status(), UpdatePartial)
+			}
+			errs := data["errors"].([]UpdateIssue)
+			if len(errs) != 1 || errs[0].Target != "repo core/x86_64" {
+				t.Errorf("got errors %+v, want a refusal of repo core/x86_64", errs)
+			}
+			if n := countRows(t, &Package{}); n != 10 {
+				t.Errorf("got %d packages, want the 10 packages kept", n)
+			}
+			var flagged int64
+			dbsingleton.Model(&Package{}).Where("flag_id <> 0").Count(&flagged)
+			if n := countRows(t, &Flag{}); n != 10 || flagged != 10 {
+				t.Errorf("got %d flags on %d packages, want the 10 flags kept", n, flagged)
+			}
+			for k, st := range findRepoStates() {
+				if st.Hash != states[k].Hash {
+					t.Errorf("the state of %s was saved", k)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRemovals(t *testing.T) {
+	oldPackages := []Package{
+		{Model: gorm.Model{ID: 1}, Repository: "core", RepoArch: "x86_64"},
+		{Model: gorm.Model{ID: 2}, Repository: "core", RepoArch: "x86_64"},
+		{Model: gorm.Model{ID: 3}, Repository: "core"},
+		{Model: gorm.Model{ID: 4}, Repository: "core"},
+		{Model: gorm.Model{ID: 5}, Repository: "main", RepoArch: "aarch64"},
+		{Model: gorm.Model{ID: 6}, Repository: "main", RepoArch: "aarch64"},
+	}
+
+	tests := []struct {
+		name       string
+		remove     []uint
+		maxRemoval int
+		want       []string
+	}{
+		{"half", []uint{1, 5}, 50, nil},
+		// The packages without architecture count for the default one.
+		{"default arch", []uint{1, 3, 4}, 50, []string{"core/x86_64"}},
+		{"several repos", []uint{1, 2, 3, 5, 6}, 50, []string{"core/x86_64", "main/aarch64"}},
+		{"no limit", []uint{1, 2, 3, 4, 5, 6}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		var remove []Package
+		for _, id := range tt.remove {
+			remove = append(remove, oldPackages[id-1])
+		}
+
+		refused := checkRemovals(oldPackages, remove, "x86_64", tt.maxRemoval)
+		var got []string
+		for k := range refused {
+			got = append(got, k)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: refused %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkUpdatePackages measures a forced update of a repository
+// of 2000 packages owning 40 files each.
+// samplePeakHeap samples the heap in use until the returned function
+// is called, which returns its high-water mark.
+func samplePeakHeap() (stop func() uint64) {
+	done, result := make(chan bool), make(chan uint64)
+
+	go func() {
+		var (
+			ms     runtime.MemStats
+			peak   uint64
+			ticker = time.NewTicker(time.Millisecond)
+		)
+		defer ticker.Stop()
+
+		for {
+			runtime.ReadMemStats(&ms)
+			if ms.HeapInuse > peak {
+				peak = ms.HeapInuse
+			}
+			select {
+			case <-done:
+				result <- peak
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		return <-result
+	}
+}
+
+func BenchmarkUpdatePackages(b *testing.B) {
+	base := b.TempDir()
+	writeTestRepo(b, base, "core", 2000, 40)
+	loadTestDb(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		stop := samplePeakHeap()
+		b.StartTimer()
+
+		res := UpdatePackages(base, "files.tar.gz", []string{"core"}, nil, nil, nil, true, false)
+
+		if p := stop(); p > peak {
+			peak = p
+		}
+		if res.Status() != UpdateSuccess {
+			b.Fatalf("update %s: %v", res.Status(), res.Map())
+		}
+	}
+
+	b.ReportMetric(float64(peak), "peak-B")
+}
+
+// loadMemoryDb loads an empty in-memory database.
+func loadMemoryDb(tb testing.TB) {
+	tb.Helper()
+
+	Load(fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(tb.Name(), "/", "_")))
+	tb.Cleanup(func() {
+		if db, err := dbsingleton.DB.DB(); err == nil {
+			db.Close()
+		}
+	})
+}
+
+// testPackages returns n packages of the core repository.
+func testPackages(n int) []Package {
+	packages := make([]Package, n)
+	for i := range packages {
+		name := fmt.Sprintf("pkg%05d", i)
+		packages[i] = Package{
+			Repository:    "core",
+			Name:          name,
+			Version:       "1.0-1",
+			Arch:          "x86_64",
+			Description:   "Generated package " + name,
+			PackageSize:   123456,
+			InstalledSize: 654321,
+			URL:           "https://example.net/" + name,
+			Licenses:      SqlSlice{"GPL"},
+			Groups:        SqlSlice{"bench"},
+			BuildDate:     time.Unix(1700000000, 0),
+			Depends:       SqlSlice{"glibc", fmt.Sprintf("pkg%05d", i/2)},
+			Files:         SqlSlice{"usr/", fmt.Sprintf("usr/share/%s/", name)},
+			Sha256Sum:     strings.Repeat("0", 64),
+			Filename:      name + "-1.0-1-x86_64.pkg.tar.zst",
+			Base:          name,
+			Packager:      "Bench <bench@example.net>",
+			RepoArch:      "x86_64",
+			RepoPath:      "core",
+		}
+	}
+
+	return packages
+}
+
+func keepNone(repo, arch string) bool { return false }
+
+// saveTestPackages runs unzipPackages and updatePackages
+// as an update of the repositories would do.
+func saveTestPackages(tb testing.TB, newPackages []Package) (add, update, remove []Package, unchanged int) {
+	tb.Helper()
+
+	var removeFlags []Flag
+	add, update, remove, removeFlags, unchanged = unzipPackages(findAllPackages(), newPackages, "x86_64", keepNone)
+	if err := dbsingleton.Transaction(updatePackages(add, update, remove, removeFlags)); err != nil {
+		tb.Fatal(err)
+	}
+
+	return
+}
+
+func TestUpdatePackagesUnchanged(t *testing.T) {
+	loadMemoryDb(t)
+	packages := testPackages(100)
+	saveTestPackages(t, packages)
+
+	var before Package
+	dbsingleton.First(&before, "name = ?", "pkg00001")
+
+	packages = testPackages(100)
+	packages[0].Version = "1.0-2"
+	add, update, remove, unchanged := saveTestPackages(t, packages)
+	if len(add) != 0 || len(update) != 1 || len(remove) != 0 || unchanged != 99 {
+		t.Fatalf("got %d added, %d updated, %d removed, %d unchanged packages, want 0, 1, 0, 99",
+			len(add), len(update), len(remove), unchanged)
+	}
+
+	var after Package
+	dbsingleton.First(&after, "name = ?", "pkg00001")
+	if !after.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("unchanged package rewritten: updated at %s, then at %s", before.UpdatedAt, after.UpdatedAt)
+	}
+}
+
+// BenchmarkUnzipUpdatePackages measures an update of 10000 packages
+// where 1% of the packages change at each iteration.
+// Only the changed packages are written.
+func BenchmarkUnzipUpdatePackages(b *testing.B) {
+	const n, changed = 10000, 100
+
+	loadMemoryDb(b)
+	packages := testPackages(n)
+	saveTestPackages(b, packages)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var updated, unchanged int
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := 0; j < changed; j++ {
+			packages[(i*changed+j)%n].Version = fmt.Sprintf("1.%d-1", i+1)
+		}
+		oldPackages := findAllPackages()
+		b.StartTimer()
+
+		add, update, remove, removeFlags, u := unzipPackages(oldPackages, packages, "x86_64", keepNone)
+		if err := dbsingleton.Transaction(updatePackages(add, update, remove, removeFlags)); err != nil {
+			b.Fatal(err)
+		}
+		if len(add) != 0 || len(remove) != 0 {
+			b.Fatalf("got %d added and %d removed packages, want none", len(add), len(remove))
+		}
+		updated, unchanged = updated+len(update), unchanged+u
+	}
+
+	b.ReportMetric(float64(updated)/float64(b.N), "updated/op")
+	b.ReportMetric(float64(unchanged)/float64(b.N), "unchanged/op")
+}
+
+func TestUnzipPackagesSignatureStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(p *Package)
+		kept   bool
+	}{
+		{"unchanged", func(p *Package) {}, true},
+		{"description", func(p *Package) { p.Description = "Changed" }, true},
+		{"filename", func(p *Package) { p.Filename = "pkg00000-1.0-2-x86_64.pkg.tar.zst" }, false},
+		{"sha256sum", func(p *Package) { p.Sha256Sum = strings.Repeat("1", 64) }, false},
+		{"signature", func(p *Package) { p.PgpSig = "c2lnbmF0dXJl" }, false},
+	}
+
+	for _, tt := range tests {
+		old := testPackages(1)
+		old[0].ID = 1
+		old[0].PgpSig, old[0].SignatureStatus, old[0].SignerFingerprint = "c2ln", "valid", "0123456789ABCDEF"
+
+		packages := testPackages(1)
+		packages[0].PgpSig = "c2ln"
+		tt.change(&packages[0])
+
+		add, update, _, _, unchanged := unzipPackages(old, packages, "x86_64", keepNone)
+		if len(add) != 0 {
+			t.Fatalf("%s: got %d added packages, want none", tt.name, len(add))
+		}
+
+		var status, signer string
+		if len(update) == 1 {
+			status, signer = update[0].SignatureStatus, update[0].SignerFingerprint
+		} else if unchanged == 1 {
+			status, signer = old[0].SignatureStatus, old[0].SignerFingerprint
+		}
+		if kept := status == "valid" && signer == "0123456789ABCDEF"; kept != tt.kept {
+			t.Errorf("%s: signature status %q of %q kept = %v, want %v", tt.name, status, signer, kept, tt.kept)
+		}
+	}
+}
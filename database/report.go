@@ -0,0 +1,133 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of an update
+const (
+	UpdateSuccess = "success" // everything was updated
+	UpdatePartial = "partial" // some repositories or mirrors failed
+	UpdateFailure = "failure" // nothing was updated
+)
+
+// UpdateIssue is an error or a warning raised during an update
+// on a target (a repository, a mirror, a file…).
+type UpdateIssue struct {
+	Target  string `json:"target"`
+	Message string `json:"message"`
+}
+
+// UpdateResult is the result of an update.
+// It is safe for concurrent use.
+type UpdateResult struct {
+	sync.Mutex
+	data     map[string]any
+	errors   []UpdateIssue
+	warnings []UpdateIssue
+	timings  map[string]time.Duration
+	failed   bool
+}
+
+func newUpdateResult() *UpdateResult {
+	return &UpdateResult{
+		data:    make(map[string]any),
+		timings: make(map[string]time.Duration),
+	}
+}
+
+// Set sets a value of the result.
+func (r *UpdateResult) Set(key string, value any) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.data[key] = value
+}
+
+// Error adds an error on the target.
+// The update is then partially failed.
+func (r *UpdateResult) Error(target string, err any) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.errors = append(r.errors, UpdateIssue{target, fmt.Sprint(err)})
+}
+
+// Warning adds a warning on the target.
+func (r *UpdateResult) Warning(target string, warning any) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.warnings = append(r.warnings, UpdateIssue{target, fmt.Sprint(warning)})
+}
+
+// Fail adds an error which prevented the update to be applied.
+func (r *UpdateResult) Fail(target string, err any) {
+	r.Error(target, err)
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.failed = true
+}
+
+// Time records the duration of the step since begin.
+func (r *UpdateResult) Time(step string, begin time.Time) {
+	r.setTiming(step, time.Since(begin))
+}
+
+func (r *UpdateResult) setTiming(step string, d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.timings[step] = d
+}
+
+func (r *UpdateResult) Status() string {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.failed {
+		return UpdateFailure
+	} else if len(r.errors) > 0 {
+		return UpdatePartial
+	}
+
+	return UpdateSuccess
+}
+
+func sortIssues(issues []UpdateIssue) []UpdateIssue {
+	out := append([]UpdateIssue{}, issues...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Target < out[j].Target })
+
+	return out
+}
+
+// Map returns the result as a map,
+// with the status, the errors, the warnings and the timings.
+func (r *UpdateResult) Map() map[string]any {
+	status := r.Status()
+
+	r.Lock()
+	defer r.Unlock()
+
+	out := make(map[string]any)
+	for k, v := range r.data {
+		out[k] = v
+	}
+
+	timings := make(map[string]string)
+	for k, d := range r.timings {
+		timings[k] = d.Round(time.Millisecond).String()
+	}
+
+	out["status"] = status
+	out["errors"] = sortIssues(r.errors)
+	out["warnings"] = sortIssues(r.warnings)
+	out["timings"] = timings
+
+	return out
+}
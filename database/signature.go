@@ -0,0 +1,186 @@
+package database
+
+import (
+	"encoding/base64"
+	"path"
+	"time"
+
+	"pmanager/log"
+	"pmanager/util/pgp"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// Directory of the public keys used to verify the signatures.
+	signatureKeyring string
+
+	// If true, the signatures of the new and updated packages
+	// are verified at each update.
+	signatureAtUpdate bool
+)
+
+// InitSignatureCheck configures the verification of the signatures.
+// - keyring : directory of the public keys (armored or binary)
+// - atUpdate : if true, the signatures of the new and updated packages are verified during the updates
+func InitSignatureCheck(keyring string, atUpdate bool) {
+	signatureKeyring, signatureAtUpdate = keyring, atUpdate
+}
+
+func verifyPackage(kr *pgp.Keyring, base string, p *Package) pgp.Signature {
+	sig, _ := base64.StdEncoding.DecodeString(p.PgpSig)
+	s := kr.VerifyFile(path.Join(base, p.Dir(), p.Filename), sig)
+	p.SignatureStatus, p.SignerFingerprint = s.Status, s.Fingerprint
+
+	return s
+}
+
+func verifyRepoDb(kr *pgp.Keyring, st *RepoState) pgp.Signature {
+	s := kr.VerifyFile(st.Path, nil)
+	st.SignatureStatus, st.SignerFingerprint = s.Status, s.Fingerprint
+
+	return s
+}
+
+// reportSignature records the bad signatures in the result.
+// The unsigned files are only counted.
+func reportSignature(res *UpdateResult, target string, s pgp.Signature) {
+	switch s.Status {
+	case pgp.Invalid:
+		res.Error(target, "Invalid signature: "+s.Error.Error())
+	case pgp.UnknownKey:
+		res.Warning(target, "Signed by an unknown key "+s.Fingerprint)
+	}
+}
+
+// verifyPackages verifies the signatures of the packages
+// with a pool of workers and counts them by status.
+func verifyPackages(res *UpdateResult, kr *pgp.Keyring, base string, packages []*Package) map[string]int {
+	var (
+		signatures = make([]pgp.Signature, len(packages))
+		tasks      = make([]func(), len(packages))
+		count      = make(map[string]int)
+	)
+
+	for i, p := range packages {
+		i, p := i, p
+		tasks[i] = func() { signatures[i] = verifyPackage(kr, base, p) }
+	}
+	runTasks(repoWorkers, tasks)
+
+	for i, s := range signatures {
+		count[s.Status]++
+		reportSignature(res, "package "+packages[i].ArchRepoName(), s)
+	}
+
+	return count
+}
+
+func loadKeyring(res *UpdateResult) *pgp.Keyring {
+	kr, err := pgp.LoadKeyring(signatureKeyring)
+	if err != nil {
+		log.Errorf("Failed to load the keyring: %s\n", err)
+		res.Error("keyring "+signatureKeyring, err)
+		return nil
+	}
+
+	log.Debugf("%d keys loaded from %s\n", kr.Len(), signatureKeyring)
+
+	return kr
+}
+
+// verifyUpdate verifies the signatures of the new and updated packages
+// and of the scanned repositories databases during an update.
+func verifyUpdate(res *UpdateResult, base string, u *packagesUpdate) {
+	kr := loadKeyring(res)
+	if kr == nil {
+		return
+	}
+
+	packages := make([]*Package, 0, len(u.add)+len(u.update))
+	for i := range u.add {
+		packages = append(packages, &u.add[i])
+	}
+	for i := range u.update {
+		packages = append(packages, &u.update[i])
+	}
+	res.Set("signatures", verifyPackages(res, kr, base, packages))
+
+	for i := range u.states {
+		st := &u.states[i]
+		reportSignature(res, "database "+st.key(), verifyRepoDb(kr, st))
+	}
+}
+
+// VerifySignatures verifies the signatures of all the packages
+// and of the databases of the repositories, and stores the results.
+func VerifySignatures(base string) *UpdateResult {
+	var (
+		res      = newUpdateResult()
+		packages []Package
+		states   []RepoState
+	)
+	defer res.Time("total", time.Now())
+
+	kr := loadKeyring(res)
+	if kr == nil {
+		res.Fail("keyring "+signatureKeyring, "No keyring")
+		return res
+	}
+
+	dbsingleton.Lock()
+	dbsingleton.
+		Model(&Package{}).
+		Select("id, name, version, repository, repo_arch, repo_path, filename, pgp_sig").
+		Find(&packages)
+	dbsingleton.Find(&states)
+	dbsingleton.Unlock()
+
+	ptrs := make([]*Package, len(packages))
+	for i := range packages {
+		ptrs[i] = &packages[i]
+	}
+	res.Set("packages", verifyPackages(res, kr, base, ptrs))
+
+	count := make(map[string]int)
+	for i := range states {
+		st := &states[i]
+		s := verifyRepoDb(kr, st)
+		count[s.Status]++
+		reportSignature(res, "database "+st.key(), s)
+	}
+	res.Set("databases", count)
+
+	dbsingleton.Lock()
+	defer dbsingleton.Unlock()
+
+	err := dbsingleton.Transaction(func(tx *gorm.DB) error {
+		for _, p := range packages {
+			err := tx.Model(&Package{}).Where("id = ?", p.ID).Updates(map[string]any{
+				"signature_status":   p.SignatureStatus,
+				"signer_fingerprint": p.SignerFingerprint,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, st := range states {
+			err := tx.Model(&RepoState{}).Where("id = ?", st.ID).Updates(map[string]any{
+				"signature_status":   st.SignatureStatus,
+				"signer_fingerprint": st.SignerFingerprint,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Errorf("Failed to save the signatures: %s\n", err)
+		res.Fail("database", err)
+	}
+
+	return res
+}
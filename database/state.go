@@ -0,0 +1,131 @@
+package database
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"pmanager/log"
+
+	"gorm.io/gorm"
+)
+
+// repoScan is the scan of the database file of a repository.
+// A repository is skipped if its database file
+// didn’t change since the last scan.
+type repoScan struct {
+	repoDir
+	state       RepoState
+	skipped     bool
+	err         error
+	entryErrors []string
+	duration    time.Duration
+}
+
+func (st RepoState) key() string {
+	return st.Name + "/" + st.Arch
+}
+
+func findRepoStates() map[string]RepoState {
+	var states []RepoState
+	SearchAll(&states)
+
+	m := make(map[string]RepoState)
+	for _, st := range states {
+		m[st.key()] = st
+	}
+
+	return m
+}
+
+func hashFile(filePath string) (sum string, err error) {
+	var f *os.File
+	if f, err = os.Open(filePath); err != nil {
+		return
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err == nil {
+		sum = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	return
+}
+
+// newRepoScan compares the database file of the repository
+// with its state at the last scan. The size and the modification time
+// are checked first, then the hash if they differ.
+// If force is set, the repository is always rescanned.
+func newRepoScan(base, extension string, rd repoDir, states map[string]RepoState, force bool) *repoScan {
+	var (
+		sc       = &repoScan{repoDir: rd}
+		filePath = getRepoFilePath(base, rd, extension)
+		old, ok  = states[rd.String()]
+	)
+
+	sc.state = RepoState{Name: rd.name, Arch: rd.arch, Path: filePath}
+	if ok {
+		sc.state.ID, sc.state.CreatedAt = old.ID, old.CreatedAt
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return sc
+	}
+	sc.state.Size, sc.state.ModTime = fi.Size(), fi.ModTime()
+
+	if !force && ok && old.Path == filePath && old.Size == sc.state.Size && old.ModTime.Equal(sc.state.ModTime) {
+		sc.state.Hash, sc.skipped = old.Hash, true
+		sc.state.SignatureStatus, sc.state.SignerFingerprint = old.SignatureStatus, old.SignerFingerprint
+		return sc
+	}
+
+	if sc.state.Hash, err = hashFile(filePath); err != nil {
+		log.Debugf("\033[1;31mFailed to hash %s: %s\n\033[m", filePath, err)
+		return sc
+	}
+
+	sc.skipped = !force && ok && old.Path == filePath && old.Hash == sc.state.Hash
+	if ok && old.Hash == sc.state.Hash {
+		sc.state.SignatureStatus, sc.state.SignerFingerprint = old.SignatureStatus, old.SignerFingerprint
+	}
+
+	return sc
+}
+
+// updateRepoStates saves the states of the scanned repositories
+// and removes the others (removed or failed repositories),
+// so they will be scanned again at the next update.
+// If repos is not empty, only the states of these repositories are updated.
+func updateRepoStates(states []RepoState, repos []string) func(*gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		var ids []uint
+		for _, st := range states {
+			if st.ID != 0 {
+				ids = append(ids, st.ID)
+			}
+		}
+
+		q := tx.Unscoped()
+		if len(repos) > 0 {
+			q = q.Where("name IN ?", repos)
+		}
+		if len(ids) > 0 {
+			q = q.Where("id NOT IN ?", ids)
+		} else {
+			q = q.Where("1 = 1")
+		}
+		if err := q.Delete(&RepoState{}).Error; err != nil {
+			return err
+		}
+
+		if len(states) == 0 {
+			return nil
+		}
+
+		return tx.Save(&states).Error
+	}
+}
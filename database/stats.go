@@ -0,0 +1,114 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// PackagerStats are the statistics of the packages of a packager.
+type PackagerStats struct {
+	Packager          string
+	Packages          int
+	Repos             map[string]int // Number of packages by repository
+	Flagged           int            // Number of currently flagged packages
+	LastBuilds        []Package      // Most recent builds
+	ResolvedFlags     int
+	AverageResolution time.Duration // Average time between the creation and the removal of the flags
+}
+
+const unknownPackager = "Unknown Packager"
+
+func packagerName(p Package) string {
+	if p.Packager == "" {
+		return unknownPackager
+	}
+
+	return p.Packager
+}
+
+// GetPackagerStats returns the statistics of the packagers,
+// sorted by decreasing number of packages.
+// The resolved flags (soft-deleted flags) are attributed
+// to the packager of the package when it was flagged.
+// - recent : number of the most recent builds to return for each packager
+func GetPackagerStats(recent int) ([]*PackagerStats, error) {
+	var (
+		packages []Package
+		flags    []Flag
+	)
+
+	dbsingleton.Lock()
+	err := dbsingleton.
+		Model(&Package{}).
+		Select("id, name, version, repository, repo_arch, packager, build_date, flag_id").
+		Find(&packages).
+		Error
+	if err == nil {
+		err = dbsingleton.
+			Unscoped().
+			Where("deleted_at IS NOT NULL AND packager <> ''").
+			Find(&flags).
+			Error
+	}
+	dbsingleton.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*PackagerStats)
+	getStats := func(name string) *PackagerStats {
+		st, ok := stats[name]
+		if !ok {
+			st = &PackagerStats{
+				Packager: name,
+				Repos:    make(map[string]int),
+			}
+			stats[name] = st
+		}
+		return st
+	}
+
+	for _, p := range packages {
+		st := getStats(packagerName(p))
+		st.Packages++
+		st.Repos[p.Repository]++
+		if p.FlagID != 0 {
+			st.Flagged++
+		}
+		st.LastBuilds = append(st.LastBuilds, p)
+	}
+
+	// The flags created before the packager was recorded are ignored.
+	resolution := make(map[string]time.Duration)
+	for _, f := range flags {
+		st := getStats(f.Packager)
+		st.ResolvedFlags++
+		resolution[f.Packager] += f.DeletedAt.Time.Sub(f.CreatedAt)
+	}
+
+	out := make([]*PackagerStats, 0, len(stats))
+	for name, st := range stats {
+		if st.ResolvedFlags > 0 {
+			st.AverageResolution = resolution[name] / time.Duration(st.ResolvedFlags)
+		}
+
+		sort.Slice(st.LastBuilds, func(i, j int) bool {
+			return st.LastBuilds[i].BuildDate.After(st.LastBuilds[j].BuildDate)
+		})
+		if len(st.LastBuilds) > recent {
+			st.LastBuilds = st.LastBuilds[:recent]
+		}
+
+		out = append(out, st)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Packages != out[j].Packages {
+			return out[i].Packages > out[j].Packages
+		}
+		return out[i].Packager < out[j].Packager
+	})
+
+	return out, nil
+}
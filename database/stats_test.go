@@ -0,0 +1,37 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestPackagerStatsResolvedFlags(t *testing.T) {
+	loadMemoryDb(t)
+	saveTestPackages(t, testPackages(3))
+
+	var packages []Package
+	dbsingleton.Where("name = ?", "pkg00001").Find(&packages)
+	if err := CreateFlags(packages, Flag{Email: "user@example.net", Comment: "outdated"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The package is rebuilt by another packager, which resolves the flag.
+	updated := testPackages(3)
+	updated[1].Version, updated[1].Packager = "1.1-1", "Other <other@example.net>"
+	saveTestPackages(t, updated)
+
+	stats, err := GetPackagerStats(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]*PackagerStats)
+	for _, st := range stats {
+		got[st.Packager] = st
+	}
+	if st := got["Bench <bench@example.net>"]; st == nil || st.Packages != 2 || st.ResolvedFlags != 1 {
+		t.Errorf("the resolved flag must be attributed to the packager of the flagged package, got %+v", st)
+	}
+	if st := got["Other <other@example.net>"]; st == nil || st.Packages != 1 || st.ResolvedFlags != 0 {
+		t.Errorf("the new packager must have no resolved flag, got %+v", st)
+	}
+}
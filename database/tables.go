@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,6 +16,10 @@ func versionName(name, version string) string {
 	return fmt.Sprintf("%s-%s", name, version)
 }
 
+func archRepoName(repo, arch, name string) string {
+	return fmt.Sprintf("%s/%s/%s", repo, arch, name)
+}
+
 func fullName(repo, name, version string) string {
 	return fmt.Sprintf("%s/%s-%s", repo, name, version)
 }
@@ -33,52 +38,73 @@ type (
 		Version    string
 		Arch       string
 		Repository string
+		Packager   string // Packager of the flagged package when the flag was created
 		Email      string
 		Comment    string
 	}
 
 	Package struct {
 		gorm.Model
-		Repository    string
-		Name          string
-		Version       string
-		Arch          string
-		Description   string
-		PackageSize   int64
-		InstalledSize int64
-		URL           string
-		Licenses      SqlSlice `gorm:"type:blob"`
-		Groups        SqlSlice `gorm:"type:blob"`
-		BuildDate     time.Time
-		Depends       SqlSlice `gorm:"type:blob"`
-		MakeDepends   SqlSlice `gorm:"type:blob"`
-		OptDepends    SqlSlice `gorm:"type:blob"`
-		Files         SqlSlice `gorm:"type:blob"`
-		Md5Sum        string
-		Sha256Sum     string
-		Filename      string
-		FlagID        uint
-		Flag          Flag
-		GitID         uint
-		Git           Git
-		BuildVersion  *Package `gorm:"-"`
+		Repository        string
+		Name              string
+		Version           string
+		Arch              string
+		Description       string
+		PackageSize       int64
+		InstalledSize     int64
+		URL               string
+		Licenses          SqlSlice `gorm:"type:blob"`
+		Groups            SqlSlice `gorm:"type:blob"`
+		BuildDate         time.Time
+		Depends           SqlSlice `gorm:"type:blob"`
+		MakeDepends       SqlSlice `gorm:"type:blob"`
+		OptDepends        SqlSlice `gorm:"type:blob"`
+		Provides          SqlSlice `gorm:"type:blob"`
+		Files             SqlSlice `gorm:"type:blob"`
+		Md5Sum            string
+		Sha256Sum         string
+		Filename          string
+		Base              string // Name of the pkgbase (PKGBUILD) the package is built from
+		Packager          string
+		PgpSig            string // Base64-encoded detached signature of the package file
+		SignatureStatus   string // Result of the last verification of the signature (empty if not verified)
+		SignerFingerprint string
+		RepoArch          string // Architecture of the repository
+		RepoPath          string // Directory of the repository, relative to the base directory
+		FlagID            uint
+		Flag              Flag
+		GitID             uint
+		Git               Git
+		BuildVersion      *Package `gorm:"-"`
 	}
 
 	Repo struct {
 		gorm.Model
-		Name       string
-		Sync       bool
-		MirrorID   uint
-		md5        string `gorm:"-"`
-		mirrorName string `gorm:"-"`
+		Name     string
+		Arch     string
+		Sync     bool
+		Error    string
+		MirrorID uint
+		md5      string `gorm:"-"`
+		url      string `gorm:"-"`
 	}
 
 	Mirror struct {
 		gorm.Model
-		Name      string
-		Online    bool
-		Repos     []Repo
-		CountryID uint
+		Name              string
+		Server            string // Server URL as declared in the mirrorlist (with $repo and $arch)
+		Online            bool
+		CountryCode       string
+		ContinentCode     string
+		Https             bool
+		CertificateExpiry time.Time
+		RedirectsToHttps  bool
+		IPv4              bool `gorm:"column:ipv4"`
+		IPv6              bool `gorm:"column:ipv6"`
+		Error             string
+		CapabilityError   string // Failures of the checks of the capabilities (HTTPS, redirection, IPv4, IPv6)
+		Repos             []Repo
+		CountryID         uint
 	}
 
 	Country struct {
@@ -86,6 +112,55 @@ type (
 		Name    string
 		Mirrors []Mirror
 	}
+
+	MirrorApplication struct {
+		gorm.Model
+		URL       string
+		Country   string
+		Operator  string
+		Email     string
+		Comment   string
+		Status    string
+		Checks    int
+		Successes int
+		LastCheck time.Time
+		LastError string
+	}
+
+	// RepoState is the state of a database file of a repository
+	// at the time of its last scan.
+	RepoState struct {
+		gorm.Model
+		Name              string
+		Arch              string
+		Path              string
+		Size              int64
+		ModTime           time.Time
+		Hash              string
+		SignatureStatus   string
+		SignerFingerprint string
+	}
+
+	// FileHash is the cached checksums of a package file,
+	// valid as long as its size and its modification time don’t change.
+	FileHash struct {
+		gorm.Model
+		Path      string `gorm:"uniqueIndex"`
+		Size      int64
+		ModTime   time.Time
+		Md5Sum    string
+		Sha256Sum string
+	}
+)
+
+const (
+	ApplicationPending  = "pending"
+	ApplicationApproved = "approved"
+	ApplicationRejected = "rejected"
+
+	// Minimal rate of successful checks
+	// to validate a mirror application.
+	applicationMinSuccessRate = 0.9
 )
 
 func (f Flag) RepoName() string {
@@ -112,6 +187,47 @@ func (p Package) FullName() string {
 	return fullName(p.Repository, p.Name, p.Version)
 }
 
+// PkgBase returns the name of the pkgbase of the package.
+// For packages without pkgbase, it is the name of the package.
+func (p Package) PkgBase() string {
+	if p.Base != "" {
+		return p.Base
+	}
+
+	return p.Name
+}
+
+// Dir returns the directory of the package file,
+// relative to the base directory of the repositories.
+func (p Package) Dir() string {
+	if p.RepoPath != "" {
+		return p.RepoPath
+	}
+
+	return p.Repository
+}
+
+// ArchRepoName returns the unique key of the package
+// (repository, architecture and name).
+func (p Package) ArchRepoName() string {
+	return archRepoName(p.Repository, p.RepoArch, p.Name)
+}
+
+// RepoURL returns the URL of the given repository and architecture on the mirror.
+func (m Mirror) RepoURL(repo, arch string) string {
+	if m.Server == "" {
+		return m.Name + repo + "/"
+	}
+
+	url := strings.ReplaceAll(m.Server, "$repo", repo)
+	url = strings.ReplaceAll(url, "$arch", arch)
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+
+	return url
+}
+
 func (p1 Package) Equal(p2 Package) bool {
 	return p1.ID == p2.ID &&
 		p1.Repository == p2.Repository &&
@@ -126,10 +242,30 @@ func (p1 Package) Equal(p2 Package) bool {
 		p1.Groups.Equal(p2.Groups) &&
 		p1.BuildDate.Equal(p2.BuildDate) &&
 		p1.Depends.Equal(p2.Depends) &&
+		p1.MakeDepends.Equal(p2.MakeDepends) &&
+		p1.OptDepends.Equal(p2.OptDepends) &&
+		p1.Provides.Equal(p2.Provides) &&
 		p1.Files.Equal(p2.Files) &&
 		p1.Md5Sum == p2.Md5Sum &&
 		p1.Sha256Sum == p2.Sha256Sum &&
 		p1.Filename == p2.Filename &&
+		p1.Base == p2.Base &&
+		p1.Packager == p2.Packager &&
+		p1.PgpSig == p2.PgpSig &&
+		p1.SignatureStatus == p2.SignatureStatus &&
+		p1.SignerFingerprint == p2.SignerFingerprint &&
+		p1.RepoArch == p2.RepoArch &&
+		p1.RepoPath == p2.RepoPath &&
 		p1.FlagID == p2.FlagID &&
 		p1.GitID == p2.GitID
 }
+
+// IsValidated returns true if the probation period of the application is over
+// and if the mirror was online and synced in most of the checks.
+func (a MirrorApplication) IsValidated() bool {
+	if a.Checks == 0 || time.Since(a.CreatedAt) < applicationProbation {
+		return false
+	}
+
+	return float64(a.Successes)/float64(a.Checks) >= applicationMinSuccessRate
+}
@@ -1,15 +1,22 @@
 module pmanager
 
 require (
+	github.com/ProtonMail/go-crypto v1.0.0
 	github.com/klauspost/compress v1.16.0
+	github.com/oschwald/maxminddb-golang v1.12.0
+	github.com/pierrec/lz4/v4 v4.1.21
+	github.com/ulikunitz/xz v0.5.12
 	gorm.io/driver/sqlite v1.4.4
 	gorm.io/gorm v1.24.6
 )
 
 require (
+	github.com/cloudflare/circl v1.3.3 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/mattn/go-sqlite3 v1.14.16 // indirect
+	golang.org/x/crypto v0.7.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
 
 go 1.19
@@ -3,30 +3,40 @@ package main
 import (
 	"fmt"
 	"os"
+	"pmanager/cmd/clean"
 	"pmanager/cmd/flag"
+	"pmanager/cmd/graph"
 	"pmanager/cmd/mailtest"
+	"pmanager/cmd/mirror"
 	"pmanager/cmd/serve"
 	"pmanager/cmd/update"
 	"pmanager/log"
+	"strconv"
+	"strings"
 
 	_ "pmanager/conf"
 )
 
 var actions = map[string]func(){
-	"update-repos":   update.Repositories,
-	"update-mirrors": update.Mirrors,
-	"update-all":     update.All,
-	"serve":          serve.Exec,
-	"flag":           flag.Exec,
-	"test-mail":      mailtest.SendMail,
-	"help":           printUsage,
+	"update-repos":      update.Repositories,
+	"update-mirrors":    update.Mirrors,
+	"update-all":        update.All,
+	"verify-signatures": update.Signatures,
+	"verify":            update.Integrity,
+	"repo-clean":        clean.Exec,
+	"graph":             graph.Exec,
+	"serve":             serve.Exec,
+	"flag":              flag.Exec,
+	"mirror":            mirror.Exec,
+	"test-mail":         mailtest.SendMail,
+	"help":              printUsage,
 }
 
 const help = `
 Available subcommands:
 
-  update-repos
-    update the list of packages in all repos
+  update-repos [<repo>...]
+    update the list of packages in all repos (or only in the given repos)
 
   update-mirrors
     update the mirrors
@@ -34,12 +44,42 @@ Available subcommands:
   update-all
     do update-repos and update-mirrors together
 
+  verify-signatures
+    verify the OpenPGP signatures of all the packages and repositories databases
+    with the keys of the keyring directory
+
+  verify [repo...]
+    check that the files of the packages (of all the repositories or of the given ones)
+    exist, aren’t empty and match the checksums of the repositories databases
+    (the checksums are cached and only recomputed if the size or the date of a file change)
+
+  repo-clean
+    list the package files (and their signatures) of the repositories directories
+    which aren’t referenced by the database anymore, with the reclaimable space
+    --dry-run: only list the files (default)
+    --apply: remove the files (or move them if --move-to is given)
+    --older-than <days>: only select the files older than the given number of days
+    --move-to <dir>: move the files to the given archive directory instead of removing them
+
+  graph <repo>/<pkgname>
+    print the dependency graph of the package
+    --arch <arch>: architecture of the repository (default: the first found)
+    --depth <n>: maximal depth of the graph (default: 1, 0 for the package only, -1 for no limit)
+    --kinds <kind1>,<kind2>,...: kinds of dependencies (depends, makedepends, optdepends – default: depends)
+    --format (dot|graphml|json): output format (default: dot)
+
+  The update and verify subcommands exit with status 1 if nothing was updated
+  and 2 if some repositories, mirrors or files failed (see the errors of the result).
+
   serve
     Launch the API webserver
 
   flag
     Launch the prompt to manage the flags
 
+  mirror
+    Launch the prompt to approve or reject the mirror applications
+
   test-mail
     Try to send mail from reading configuration
 
@@ -51,12 +91,20 @@ Available arguments:
   --log (<filepath>|stdout|stderr)
     Force to write the log in the specified path (or standard output/error).
     If not present, use the log value in the configuration.
+  --force
+    (update-repos, update-all) Rescan all the repositories,
+    even if their database didn’t change since the last update,
+    and allow to remove more than max_removal% of the packages of a repository.
+  --dry-run
+    (update-repos, update-mirrors, update-all) Display the changes of the update
+    (packages, flags and mirrors) without writing them in the database.
 
 Available Routes:
 
   /flag/list
     search=<pkgname pattern>
     repo=<repository>
+    arch=<architecture of the repository>
     email=<email of the submitter>
     from=<minimum date of submission>
     to=<maximum date of submission>
@@ -69,42 +117,134 @@ Available Routes:
     name=<pkgname>
     version=<pkgver>
     repo=<repository>
+    arch=<architecture of the repository> (optional)
+    pkgbase=(0|1) (flag all the packages built from the same pkgbase, with only one notification)
     email=<email of submitter>
     comment=<comment of submitter>
 
   /flag/delete (INNER USE ONLY!)
     ids=<list of flag IDs separated by comma>
 
+  /pkgbase/view
+    name=<repo/pkgbase> (list the split packages built from the pkgbase)
+    arch=<architecture of the repository> (optional)
+
   /package/view
     name=<repo/pkgname-pkgver>
+    arch=<architecture of the repository> (optional)
+
+  /download/<repository>[/<architecture>]/<filename>
+    redirect to a synced mirror near the client (or to the main repository)
+
+  /package/metalink
+    name=<repo/pkgname> (returns a metalink document (RFC 5854) listing the synced mirrors)
+
+  /package/graph
+    name=<repo>/<pkgname>
+    arch=<architecture of the repository>
+    depth=<maximal depth of the graph> (default: 1, 0 for the package only, -1 for no limit)
+    kinds=<kind1>,<kind2>,... (depends|makedepends|optdepends – default: depends)
+    format=(dot|graphml|json) (default: json)
 
   /package/list
     exact=(0|1) (to search package with exact name)
     search=<pkgname pattern>
+    arch=<architecture of the repository>
     from=<minimum date of build>
     to=<maximum date of build>
+    group=<name of a group>
     flagged=(0|1)
     sortby=(repo|name|date|flagged)
     sortdir=(asc|desc) (default: asc)
     page=<page number to display>
     limit=<max number of result> (default: defined in configuration, parameter pagination of section [api])
 
+  /group/list
+    repo=<repository>
+    arch=<architecture of the repository>
+
+  /group/view
+    name=<name of the group>
+    repo=<repository>
+    arch=<architecture of the repository>
+
+  /license/list
+    repo=<repository>
+    arch=<architecture of the repository>
+
+  /report/licenses
+    issue=(no_license|non_spdx|deprecated|custom_without_file)
+    repo=<repository>
+    arch=<architecture of the repository>
+    (the version of the SPDX license list used is returned in spdx_version, as for /license/list)
+
   /repo/list
     repo=<repository>
     search=<pkgname pattern>
+    arch=<architecture of the repository>
     from=<minimum date of build>
     to=<maximum date of build>
+    group=<name of a group>
     flagged=(0|1)
     sortby=(repo|name|date|flagged)
     sortdir=(asc|desc) (default: asc)
     page=<page number to display>
     limit=<max number of result> (default: defined in configuration, parameter pagination of section [api])
 
+  /mirror/apply
+    url=<base URL of the mirror>
+    country=<country of the mirror>
+    operator=<name of the mirror operator>
+    email=<email of the mirror operator>
+    comment=<comment of the operator>
+
+  /mirror/application/list (INNER USE ONLY!)
+    status=(pending|approved|rejected) (default: pending)
+
+  /mirror/application/approve (INNER USE ONLY!)
+    ids=<list of application IDs separated by comma>
+
+  /mirror/application/reject (INNER USE ONLY!)
+    ids=<list of application IDs separated by comma>
+
+  /mirror/nearest
+    limit=<max number of mirrors> (default: all)
+
+  /stats/packagers
+    recent=<number of the most recent builds by packager> (default: 5)
+    (packages by repository, flagged packages and average time to resolve a flag of each packager)
+
+  /repo/pacman
+    list the repositories declared in the pacman configuration (with their servers and signature levels)
+
+  /update/mirror, /update/repo, /update/all (INNER USE ONLY!)
+    return the status of the update (success|partial|failure), its errors, warnings and timings
+    (HTTP status 207 if partial, 500 if failure)
+
   /update/mirror (INNER USE ONLY!)
+    dry_run=(0|1) (display the changes without applying them)
 
   /update/repo (INNER USE ONLY!)
+    name=<list of repositories separated by comma> (default: all)
+    force=(0|1) (rescan the unchanged repositories and allow mass removals)
+    dry_run=(0|1) (display the changes without applying them)
+
+  /update/signature (INNER USE ONLY!)
+    verify the signatures of all the packages and repositories databases
+
+  /report/signatures
+    status=(valid|invalid|unknown_key|unsigned|no_file|unchecked) (default: all but valid)
+    repo=<repository>
+    arch=<architecture of the repository>
+    page=<page number to display>
+    limit=<max number of result> (default: defined in configuration, parameter pagination of section [api])
+
+  /report/integrity (INNER USE ONLY!)
+    name=<repository 1>,<repository 2>,... (check only the given repositories)
 
   /update/all (INNER USE ONLY!)
+    force=(0|1) (rescan the unchanged repositories and allow mass removals)
+    dry_run=(0|1) (display the changes without applying them)
 `
 
 func init() {
@@ -117,6 +257,38 @@ func init() {
 				log.Debug = true
 			case "--no-debug":
 				log.Debug = false
+			case "--force":
+				update.Force = true
+			case "--dry-run":
+				update.DryRun = true
+				clean.DryRun = true
+			case "--apply":
+				clean.Apply = true
+			case "--older-than":
+				e, args = argValue(args)
+				days, err := strconv.Atoi(e)
+				if err != nil || days < 0 {
+					printUsage()
+					os.Exit(1)
+				}
+				clean.OlderThan = days
+			case "--move-to":
+				clean.MoveTo, args = argValue(args)
+			case "--arch":
+				graph.Arch, args = argValue(args)
+			case "--depth":
+				e, args = argValue(args)
+				depth, err := strconv.Atoi(e)
+				if err != nil {
+					printUsage()
+					os.Exit(1)
+				}
+				graph.Depth = depth
+			case "--kinds":
+				e, args = argValue(args)
+				graph.Kinds = strings.Split(e, ",")
+			case "--format":
+				graph.Format, args = argValue(args)
 			case "--log":
 				if len(args) > 0 {
 					e, args = args[0], args[1:]
@@ -126,6 +298,14 @@ func init() {
 					os.Exit(1)
 				}
 			default:
+				if (os.Args[1] == "update-repos" || os.Args[1] == "verify") && !strings.HasPrefix(e, "-") {
+					update.Repos = append(update.Repos, e)
+					continue
+				}
+				if os.Args[1] == "graph" && graph.Name == "" && !strings.HasPrefix(e, "-") {
+					graph.Name = e
+					continue
+				}
 				printUsage()
 				os.Exit(1)
 			}
@@ -133,6 +313,17 @@ func init() {
 	}
 }
 
+// argValue returns the value of an option
+// and exits if it is missing.
+func argValue(args []string) (string, []string) {
+	if len(args) == 0 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	return args[0], args[1:]
+}
+
 func printUsage() {
 	w := os.Stderr
 
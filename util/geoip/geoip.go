@@ -0,0 +1,99 @@
+package geoip
+
+import (
+	"errors"
+	"net"
+	"net/url"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is the geographical position of an IP address.
+type Location struct {
+	Country   string // ISO 3166-1 code of the country
+	Continent string // Two-letters code of the continent
+}
+
+func (l Location) IsZero() bool {
+	return l.Country == "" && l.Continent == ""
+}
+
+type record struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+}
+
+// Reader is a reader of a MaxMind-format database (.mmdb).
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+// Open loads the given .mmdb file.
+func Open(uri string) (r *Reader, err error) {
+	var db *maxminddb.Reader
+
+	if db, err = maxminddb.Open(uri); err == nil {
+		r = &Reader{db: db}
+	}
+
+	return
+}
+
+func (r *Reader) Close() error {
+	if r == nil || r.db == nil {
+		return nil
+	}
+
+	return r.db.Close()
+}
+
+// Lookup returns the location of the given IP address.
+func (r *Reader) Lookup(ip net.IP) (loc Location, err error) {
+	if r == nil || r.db == nil {
+		err = errors.New("GeoIP database not loaded")
+		return
+	}
+	if ip == nil {
+		err = errors.New("Invalid IP address")
+		return
+	}
+
+	var rec record
+	if err = r.db.Lookup(ip, &rec); err == nil {
+		loc.Country, loc.Continent = rec.Country.IsoCode, rec.Continent.Code
+	}
+
+	return
+}
+
+// LookupHost resolves the host of the given URL (or hostname)
+// and returns the location of its first known address.
+func (r *Reader) LookupHost(uri string) (loc Location, err error) {
+	host := uri
+	if u, e := url.Parse(uri); e == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if ips, err = net.LookupIP(host); err != nil {
+		return
+	}
+
+	for _, ip := range ips {
+		if loc, err = r.Lookup(ip); err == nil && !loc.IsZero() {
+			return
+		}
+	}
+
+	if err == nil {
+		err = errors.New("No location found for " + host)
+	}
+
+	return
+}
@@ -0,0 +1,263 @@
+package graph
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"pmanager/util/conv"
+	"sort"
+	"strings"
+)
+
+// Output formats
+const (
+	Dot     = "dot"
+	GraphML = "graphml"
+	Json    = "json"
+)
+
+var mimeTypes = map[string]string{
+	Dot:     "text/vnd.graphviz",
+	GraphML: "application/graphml+xml",
+	Json:    "application/json",
+}
+
+// Styles of the edges in the dot format by kind
+var dotStyles = map[string]string{
+	"makedepends": "dashed",
+	"optdepends":  "dotted",
+}
+
+// Node is a vertex of the graph.
+// Attrs are additional informations exported as data in GraphML.
+type Node struct {
+	ID      string            `json:"id"`
+	Label   string            `json:"label"`
+	Missing bool              `json:"missing,omitempty"` // node not found (unresolved dependency)
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// Edge is a directed edge of the graph.
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Label string `json:"label,omitempty"`
+}
+
+// Graph is a directed graph from a root node.
+type Graph struct {
+	Root  string `json:"root"`
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	index map[string]bool
+}
+
+// New returns an empty graph.
+func New(root string) *Graph {
+	return &Graph{
+		Root:  root,
+		Nodes: []Node{},
+		Edges: []Edge{},
+		index: make(map[string]bool),
+	}
+}
+
+// MimeType returns the MIME type of the format.
+func MimeType(format string) string {
+	return mimeTypes[format]
+}
+
+// IsFormat returns true if the format is supported.
+func IsFormat(format string) bool {
+	_, ok := mimeTypes[format]
+
+	return ok
+}
+
+// HasNode returns true if the node is already in the graph.
+func (g *Graph) HasNode(id string) bool {
+	return g.index[id]
+}
+
+// AddNode adds the node if it is not already in the graph.
+func (g *Graph) AddNode(n Node) *Graph {
+	if !g.index[n.ID] {
+		g.index[n.ID] = true
+		g.Nodes = append(g.Nodes, n)
+	}
+
+	return g
+}
+
+func (g *Graph) AddEdge(e Edge) *Graph {
+	g.Edges = append(g.Edges, e)
+
+	return g
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	return `"` + r.Replace(s) + `"`
+}
+
+// WriteDot writes the graph in the dot format of Graphviz.
+func (g Graph) WriteDot(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %s {\n", quote(g.Root))
+	b.WriteString("    node [shape=box];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + quote(n.Label)}
+		if n.ID == g.Root {
+			attrs = append(attrs, "style=bold")
+		}
+		if n.Missing {
+			attrs = append(attrs, "color=red", "fontcolor=red")
+		}
+		fmt.Fprintf(&b, "    %s [%s];\n", quote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{}
+		if e.Label != "" {
+			attrs = append(attrs, "label="+quote(e.Label))
+		}
+		if style, ok := dotStyles[e.Kind]; ok {
+			attrs = append(attrs, "style="+style)
+		}
+		fmt.Fprintf(&b, "    %s -> %s", quote(e.From), quote(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphml struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphmlNode `xml:"node"`
+		Edges       []graphmlEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// WriteGraphML writes the graph in the GraphML format.
+func (g Graph) WriteGraphML(w io.Writer, beautify bool) (err error) {
+	var (
+		doc   graphml
+		attrs = make(map[string]bool)
+	)
+
+	for _, n := range g.Nodes {
+		for k := range n.Attrs {
+			attrs[k] = true
+		}
+	}
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	doc.Keys = []graphmlKey{
+		{ID: "label", For: "node", Name: "label", Type: "string"},
+		{ID: "missing", For: "node", Name: "missing", Type: "boolean"},
+	}
+	for _, k := range names {
+		doc.Keys = append(doc.Keys, graphmlKey{ID: k, For: "node", Name: k, Type: "string"})
+	}
+	doc.Keys = append(
+		doc.Keys,
+		graphmlKey{ID: "kind", For: "edge", Name: "kind", Type: "string"},
+		graphmlKey{ID: "constraint", For: "edge", Name: "constraint", Type: "string"},
+	)
+
+	doc.Graph.ID, doc.Graph.EdgeDefault = g.Root, "directed"
+	for _, n := range g.Nodes {
+		gn := graphmlNode{
+			ID: n.ID,
+			Data: []graphmlData{
+				{"label", n.Label},
+				{"missing", fmt.Sprint(n.Missing)},
+			},
+		}
+		for _, k := range names {
+			if v, ok := n.Attrs[k]; ok {
+				gn.Data = append(gn.Data, graphmlData{k, v})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+	for _, e := range g.Edges {
+		ge := graphmlEdge{
+			Source: e.From,
+			Target: e.To,
+			Data:   []graphmlData{{"kind", e.Kind}},
+		}
+		if e.Label != "" {
+			ge.Data = append(ge.Data, graphmlData{"constraint", e.Label})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ge)
+	}
+
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return
+	}
+
+	e := xml.NewEncoder(w)
+	if beautify {
+		e.Indent("", "    ")
+	}
+	if err = e.Encode(doc); err == nil {
+		_, err = io.WriteString(w, "\n")
+	}
+
+	return
+}
+
+// Write writes the graph in the given format (dot, graphml or json).
+func (g Graph) Write(w io.Writer, format string, beautify bool) error {
+	switch format {
+	case Dot:
+		return g.WriteDot(w)
+	case GraphML:
+		return g.WriteGraphML(w, beautify)
+	case Json:
+		return conv.WriteJson(w, g, beautify)
+	}
+
+	return errors.New("Unknown format " + format)
+}
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testGraph() *Graph {
+	g := New("core/x86_64/app")
+	g.AddNode(Node{
+		ID:    "core/x86_64/app",
+		Label: "app-1.0-1",
+		Attrs: map[string]string{"name": "app", "version": "1.0-1", "repository": "core", "arch": "x86_64"},
+	})
+	g.AddNode(Node{
+		ID:    "core/x86_64/lib",
+		Label: "lib-2.0-1",
+		Attrs: map[string]string{"name": "lib", "version": "2.0-1", "repository": "core", "arch": "x86_64"},
+	})
+	g.AddNode(Node{ID: "missing/tool>=3", Label: "tool>=3", Missing: true})
+	// A node is only added once.
+	g.AddNode(Node{ID: "core/x86_64/lib", Label: "duplicate"})
+
+	g.AddEdge(Edge{From: "core/x86_64/app", To: "core/x86_64/lib", Kind: "depends", Label: `lib>=2 "quoted"`})
+	g.AddEdge(Edge{From: "core/x86_64/app", To: "missing/tool>=3", Kind: "makedepends", Label: ">=3"})
+	g.AddEdge(Edge{From: "core/x86_64/lib", To: "core/x86_64/app", Kind: "optdepends"})
+
+	return g
+}
+
+func checkGolden(t *testing.T, file string, got []byte) {
+	t.Helper()
+
+	fp := filepath.Join("testdata", file)
+	if *update {
+		if err := os.WriteFile(fp, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: got\n%s\nwant\n%s", file, got, want)
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	var b bytes.Buffer
+	if err := testGraph().Write(&b, Dot, false); err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "graph.dot", b.Bytes())
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var b bytes.Buffer
+	if err := testGraph().Write(&b, GraphML, true); err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "graph.graphml", b.Bytes())
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := testGraph().Write(new(bytes.Buffer), "svg", false); err == nil {
+		t.Error("Write() in an unknown format: no error")
+	}
+	if IsFormat("svg") || !IsFormat(Dot) {
+		t.Error("IsFormat() doesn’t match the supported formats")
+	}
+}
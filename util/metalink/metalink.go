@@ -0,0 +1,74 @@
+package metalink
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	MimeType  = "application/metalink4+xml"
+	Extension = ".meta4"
+)
+
+// Hash is the checksum of a file.
+// Type is the IANA name of the hash function (md5, sha-256, etc.).
+type Hash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// URL is a location where the file can be downloaded.
+// - Priority : lower values are preferred (1 to 999999)
+// - Location : ISO 3166-1 code of the country of the server
+type URL struct {
+	Priority int    `xml:"priority,attr,omitempty"`
+	Location string `xml:"location,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+// File is a file description of a metalink document.
+type File struct {
+	Name   string `xml:"name,attr"`
+	Size   int64  `xml:"size,omitempty"`
+	Hashes []Hash `xml:"hash"`
+	URLs   []URL  `xml:"url"`
+}
+
+// Metalink is a metalink document as described in RFC 5854.
+type Metalink struct {
+	XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:metalink metalink"`
+	Generator string   `xml:"generator,omitempty"`
+	Published string   `xml:"published,omitempty"`
+	Files     []File   `xml:"file"`
+}
+
+// New returns an empty metalink document.
+func New(generator string) *Metalink {
+	return &Metalink{
+		Generator: generator,
+		Published: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func (m *Metalink) AddFile(f File) *Metalink {
+	m.Files = append(m.Files, f)
+
+	return m
+}
+
+func (m Metalink) Write(w io.Writer, beautify bool) (err error) {
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return
+	}
+
+	e := xml.NewEncoder(w)
+	if beautify {
+		e.Indent("", "    ")
+	}
+	if err = e.Encode(m); err == nil {
+		_, err = io.WriteString(w, "\n")
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package pacman
+
+import (
+	"strings"
+)
+
+// Depend is a dependency of a package (name[op version][: description]).
+type Depend struct {
+	Name        string
+	Op          string // Comparison operator of the version (<, <=, =, >=, >), empty if no constraint
+	Version     string
+	Description string // Description of an optional dependency
+}
+
+// ParseDepend parses a dependency, a provision
+// or an optional dependency of a package.
+func ParseDepend(s string) (d Depend) {
+	if i := strings.Index(s, ": "); i >= 0 {
+		s, d.Description = s[:i], strings.TrimSpace(s[i+2:])
+	}
+	s = strings.TrimSuffix(strings.TrimSpace(s), ":")
+
+	i := strings.IndexAny(s, "<>=")
+	if i < 0 {
+		d.Name = s
+		return
+	}
+
+	d.Name, s = s[:i], s[i:]
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(s, op) {
+			d.Op, d.Version = op, s[len(op):]
+			break
+		}
+	}
+
+	return
+}
+
+// Constraint returns the version constraint of the dependency.
+func (d Depend) Constraint() string {
+	return d.Op + d.Version
+}
+
+func (d Depend) String() string {
+	return d.Name + d.Constraint()
+}
+
+// SatisfiedBy returns true if the given version satisfies
+// the version constraint of the dependency.
+// If the constraint has no release, the release of the version is ignored.
+func (d Depend) SatisfiedBy(version string) bool {
+	if d.Op == "" {
+		return true
+	} else if version == "" {
+		return false
+	}
+
+	if _, _, r := parseEVR(d.Version); r == "" {
+		if e, v, _ := parseEVR(version); e != "0" {
+			version = e + ":" + v
+		} else {
+			version = v
+		}
+	}
+
+	c := Vercmp(version, d.Version)
+	switch d.Op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	}
+
+	return false
+}
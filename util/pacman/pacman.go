@@ -0,0 +1,245 @@
+package pacman
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path/filepath"
+	"pmanager/util/resource"
+	"runtime"
+	"strings"
+)
+
+const (
+	optionsSection = "options"
+	maxIncludes    = 10
+)
+
+var archs = map[string]string{
+	"amd64": "x86_64",
+	"386":   "i686",
+	"arm64": "aarch64",
+	"arm":   "armv7h",
+}
+
+// Repository is a repository declared in the pacman configuration.
+type Repository struct {
+	Name     string
+	Servers  []string
+	SigLevel []string
+	Disabled bool // true if the section is commented out
+}
+
+// Config is the content of a pacman configuration file.
+type Config struct {
+	Architectures []string
+	SigLevel      []string
+	Repositories  []Repository
+}
+
+// Arch returns the architecture of the running system
+// with the pacman naming.
+func Arch() string {
+	if arch, ok := archs[runtime.GOARCH]; ok {
+		return arch
+	}
+
+	return runtime.GOARCH
+}
+
+type parser struct {
+	conf    *Config
+	current *Repository
+	options bool
+	index   map[string]int
+}
+
+func parseSection(line string) (name string, ok bool) {
+	l := len(line)
+	if l > 2 && line[0] == '[' && line[l-1] == ']' {
+		return strings.TrimSpace(line[1 : l-1]), true
+	}
+
+	return
+}
+
+func parseDirective(line string) (key, value string) {
+	if i := strings.Index(line, "="); i > 0 {
+		return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+	}
+
+	return line, ""
+}
+
+func (p *parser) enterSection(name string) {
+	p.options = name == optionsSection
+	if p.options {
+		p.current = nil
+		return
+	}
+
+	i, ok := p.index[name]
+	if !ok {
+		i = len(p.conf.Repositories)
+		p.index[name] = i
+		p.conf.Repositories = append(p.conf.Repositories, Repository{Name: name})
+	}
+	p.conf.Repositories[i].Disabled = false
+	p.current = &p.conf.Repositories[i]
+}
+
+func (p *parser) disableSection(name string) {
+	if _, ok := p.index[name]; ok || name == optionsSection {
+		return
+	}
+
+	p.index[name] = len(p.conf.Repositories)
+	p.conf.Repositories = append(p.conf.Repositories, Repository{Name: name, Disabled: true})
+
+	// Pointers may have been invalidated by the append.
+	if p.current != nil {
+		p.current = &p.conf.Repositories[p.index[p.current.Name]]
+	}
+}
+
+func (p *parser) include(pattern string, depth int) error {
+	if depth >= maxIncludes {
+		return errors.New("Too many nested includes: " + pattern)
+	}
+
+	files := []string{pattern}
+	if resource.IsPath(pattern) {
+		var err error
+		if files, err = filepath.Glob(pattern); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return errors.New("No file matches the include: " + pattern)
+		}
+	}
+
+	for _, f := range files {
+		r, err := resource.Open(f)
+		if err != nil {
+			return err
+		}
+		err = p.parse(r, depth+1)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parse(r io.Reader, depth int) error {
+	sc := bufio.NewScanner(r)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '#' {
+			if name, ok := parseSection(strings.TrimSpace(line[1:])); ok {
+				p.disableSection(name)
+			}
+			continue
+		}
+
+		if name, ok := parseSection(line); ok {
+			p.enterSection(name)
+			continue
+		}
+
+		key, value := parseDirective(line)
+		switch key {
+		case "Include":
+			if err := p.include(value, depth); err != nil {
+				return err
+			}
+		case "Architecture":
+			if p.options {
+				p.conf.Architectures = strings.Fields(value)
+			}
+		case "SigLevel":
+			if p.options {
+				p.conf.SigLevel = strings.Fields(value)
+			} else if p.current != nil {
+				p.current.SigLevel = strings.Fields(value)
+			}
+		case "Server":
+			if p.current != nil {
+				p.current.Servers = append(p.current.Servers, value)
+			}
+		}
+	}
+
+	return sc.Err()
+}
+
+// Parse reads the pacman configuration file (path or URL)
+// and follows its Include directives.
+func Parse(uri string) (conf *Config, err error) {
+	var r io.ReadCloser
+	if r, err = resource.Open(uri); err != nil {
+		return
+	}
+	defer r.Close()
+
+	p := parser{
+		conf:  new(Config),
+		index: make(map[string]int),
+	}
+	if err = p.parse(r, 0); err != nil {
+		return
+	}
+
+	conf = p.conf
+	for i, arch := range conf.Architectures {
+		if arch == "auto" {
+			conf.Architectures[i] = Arch()
+		}
+	}
+	if len(conf.Architectures) == 0 {
+		conf.Architectures = []string{Arch()}
+	}
+
+	return
+}
+
+// Enabled returns the repositories which are not disabled.
+func (c *Config) Enabled() (repos []Repository) {
+	for _, r := range c.Repositories {
+		if !r.Disabled {
+			repos = append(repos, r)
+		}
+	}
+
+	return
+}
+
+// Disabled returns the repositories disabled by comments.
+func (c *Config) Disabled() (repos []Repository) {
+	for _, r := range c.Repositories {
+		if r.Disabled {
+			repos = append(repos, r)
+		}
+	}
+
+	return
+}
+
+// Names returns the names of the enabled repositories.
+func (c *Config) Names() []string {
+	enabled := c.Enabled()
+	names := make([]string, len(enabled))
+
+	for i, r := range enabled {
+		names[i] = r.Name
+	}
+
+	return names
+}
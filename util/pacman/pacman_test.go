@@ -0,0 +1,73 @@
+package pacman
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	conf, err := Parse("testdata/pacman.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirrors := []string{
+		"http://mirror1.example.net/$repo/$arch",
+		"http://mirror2.example.net/$repo/$arch",
+	}
+	want := []Repository{
+		{Name: "core", Servers: append(mirrors, "http://local.example.net/$repo/$arch"), SigLevel: []string{"PackageRequired"}},
+		{Name: "core-testing", Disabled: true},
+		{Name: "testing", Servers: mirrors},
+		{Name: "main", Servers: mirrors},
+		{Name: "build", Servers: []string{"http://build.example.net/$repo/$arch"}, SigLevel: []string{"Never"}},
+		{Name: "extra", Servers: mirrors},
+	}
+	if !reflect.DeepEqual(conf.Repositories, want) {
+		t.Errorf("Repositories =\n%+v\nwant\n%+v", conf.Repositories, want)
+	}
+
+	// The [options] section of the included file overrides the global SigLevel.
+	if want := []string{"Optional"}; !reflect.DeepEqual(conf.SigLevel, want) {
+		t.Errorf("SigLevel = %v, want %v", conf.SigLevel, want)
+	}
+	if want := []string{Arch()}; !reflect.DeepEqual(conf.Architectures, want) {
+		t.Errorf("Architectures = %v, want %v", conf.Architectures, want)
+	}
+
+	if want := []string{"core", "testing", "main", "build", "extra"}; !reflect.DeepEqual(conf.Names(), want) {
+		t.Errorf("Names() = %v, want %v", conf.Names(), want)
+	}
+	if disabled := conf.Disabled(); len(disabled) != 1 || disabled[0].Name != "core-testing" {
+		t.Errorf("Disabled() = %+v, want [core-testing]", disabled)
+	}
+}
+
+func TestParseArchitectures(t *testing.T) {
+	conf, err := Parse("testdata/arch.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{Arch(), "aarch64"}; !reflect.DeepEqual(conf.Architectures, want) {
+		t.Errorf("Architectures = %v, want %v", conf.Architectures, want)
+	}
+}
+
+func TestParseIncludeErrors(t *testing.T) {
+	tests := []struct {
+		file, err string
+	}{
+		{"testdata/cycle.conf", "Too many nested includes"},
+		{"testdata/nomatch.conf", "No file matches the include"},
+		{"testdata/missing.conf", "no such file"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.file)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%s): error = %v, want %q", tt.file, err, tt.err)
+		}
+	}
+}
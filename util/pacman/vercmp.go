@@ -0,0 +1,135 @@
+package pacman
+
+import (
+	"strings"
+)
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+func span(s string, f func(byte) bool) int {
+	i := 0
+	for i < len(s) && f(s[i]) {
+		i++
+	}
+
+	return i
+}
+
+// rpmvercmp compares two version strings segment by segment,
+// as the rpmvercmp function of libalpm.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	one, two := a, b
+	for len(one) > 0 && len(two) > 0 {
+		i := span(one, func(c byte) bool { return !isAlnum(c) })
+		j := span(two, func(c byte) bool { return !isAlnum(c) })
+		one, two = one[i:], two[j:]
+
+		if len(one) == 0 || len(two) == 0 {
+			break
+		}
+		// If the separator lengths are different, we are finished.
+		if i != j {
+			if i < j {
+				return -1
+			}
+			return 1
+		}
+
+		f := isAlpha
+		isNum := isDigit(one[0])
+		if isNum {
+			f = isDigit
+		}
+		i, j = span(one, f), span(two, f)
+		seg1, seg2 := one[:i], two[:j]
+		one, two = one[i:], two[j:]
+
+		// Numeric segments are always newer than alpha segments.
+		if len(seg2) == 0 {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		if isNum {
+			seg1, seg2 = strings.TrimLeft(seg1, "0"), strings.TrimLeft(seg2, "0")
+			if len(seg1) != len(seg2) {
+				if len(seg1) < len(seg2) {
+					return -1
+				}
+				return 1
+			}
+		}
+
+		if c := strings.Compare(seg1, seg2); c != 0 {
+			return c
+		}
+	}
+
+	if len(one) == 0 && len(two) == 0 {
+		return 0
+	}
+
+	// A remaining alpha string never beats an empty string.
+	if (len(one) == 0 && (len(two) == 0 || !isAlpha(two[0]))) || (len(one) > 0 && isAlpha(one[0])) {
+		return -1
+	}
+
+	return 1
+}
+
+// parseEVR splits a version into epoch, version and release.
+func parseEVR(evr string) (epoch, version, release string) {
+	epoch, version = "0", evr
+
+	if i := span(evr, isDigit); i < len(evr) && evr[i] == ':' {
+		if i > 0 {
+			epoch = evr[:i]
+		}
+		version = evr[i+1:]
+	}
+
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		version, release = version[:i], version[i+1:]
+	}
+
+	return
+}
+
+// Vercmp compares two versions of packages ([epoch:]version[-release])
+// as vercmp of pacman. It returns -1 if a is older than b,
+// 1 if a is newer and 0 if both versions are equal.
+// The releases are only compared if both versions have one.
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	e1, v1, r1 := parseEVR(a)
+	e2, v2, r2 := parseEVR(b)
+
+	c := rpmvercmp(e1, e2)
+	if c == 0 {
+		c = rpmvercmp(v1, v2)
+	}
+	if c == 0 && r1 != "" && r2 != "" {
+		c = rpmvercmp(r1, r2)
+	}
+
+	return c
+}
@@ -0,0 +1,162 @@
+package pacman
+
+import (
+	"testing"
+)
+
+func TestVercmp(t *testing.T) {
+	// Test vectors of vercmp from pacman (test/util/vercmptest.sh)
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		// similar length, no release
+		{"1.5.0", "1.5.0", 0},
+		{"1.5.1", "1.5.0", 1},
+		// mixed length
+		{"1.5.1", "1.5", 1},
+		{"1.0", "1.0.1", -1},
+		// with release
+		{"1.5.0-1", "1.5.0-1", 0},
+		{"1.5.0-1", "1.5.0-2", -1},
+		{"1.5.0-1", "1.5.1-1", -1},
+		{"1.5.0-2", "1.5.1-1", -1},
+		{"1.5-1", "1.5.1-1", -1},
+		{"1.5-2", "1.5.1-1", -1},
+		{"1.5-2", "1.5.1-2", -1},
+		// release on one side only
+		{"1.5", "1.5-1", 0},
+		{"1.0-1", "1.0", 0},
+		{"1.1-1", "1.1", 0},
+		{"1.0-1", "1.1", -1},
+		{"1.1-1", "1.0", 1},
+		// alphanumeric versions
+		{"1.5b-1", "1.5-1", -1},
+		{"1.5b", "1.5", -1},
+		{"1.5b-1", "1.5", -1},
+		{"1.5b", "1.5.1", -1},
+		{"1.0a", "1.0", -1},
+		// from the man page
+		{"1.0a", "1.0alpha", -1},
+		{"1.0alpha", "1.0b", -1},
+		{"1.0b", "1.0beta", -1},
+		{"1.0beta", "1.0rc", -1},
+		{"1.0rc", "1.0", -1},
+		// alpha-dotted versions
+		{"1.0.a", "1.0", 1},
+		{"1.5.a", "1.5", 1},
+		{"1.5.b", "1.5.a", 1},
+		{"1.5.1", "1.5.b", 1},
+		{"1.5.b-1", "1.5.b", 0},
+		{"1.5-1", "1.5.b", -1},
+		// separators
+		{"2.0", "2_0", 0},
+		{"2.0_a", "2_0.a", 0},
+		{"2.0a", "2.0.a", -1},
+		{"2___a", "2_a", 1},
+		// leading zeros
+		{"1.01", "1.1", 0},
+		{"1.001", "1.1", 0},
+		{"1.010", "1.9", 1},
+		{"0001", "1", 0},
+		// alpha vs numeric segments
+		{"1.a", "1.1", -1},
+		{"a", "1", -1},
+		{"1.0.0a", "1.0.0.1", -1},
+		// epochs
+		{"0:1.0", "0:1.0", 0},
+		{"0:1.0", "0:1.1", -1},
+		{"1:1.0", "0:1.0", 1},
+		{"1:1.0", "0:1.1", 1},
+		{"1:1.0", "2:1.1", -1},
+		{"1:1.0", "0:1.0-1", 1},
+		{"1:1.0-1", "0:1.1-1", 1},
+		{"0:1.0", "1.0", 0},
+		{"0:1.0", "1.1", -1},
+		{"0:1.1", "1.0", 1},
+		{"1:1.0", "1.0", 1},
+		{"1:1.0", "1.1", 1},
+		{"1:1.1", "1.1", 1},
+		{"1:1.0", "2.0", 1},
+	}
+
+	for _, tt := range tests {
+		if got := Vercmp(tt.a, tt.b); got != tt.want {
+			t.Errorf("Vercmp(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Vercmp(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Vercmp(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestParseEVR(t *testing.T) {
+	tests := []struct {
+		evr, epoch, version, release string
+	}{
+		{"1.0", "0", "1.0", ""},
+		{"1.0-1", "0", "1.0", "1"},
+		{"2:1.0-1", "2", "1.0", "1"},
+		{":1.0", "0", "1.0", ""},
+		{"1.0-rc-1", "0", "1.0-rc", "1"},
+	}
+
+	for _, tt := range tests {
+		e, v, r := parseEVR(tt.evr)
+		if e != tt.epoch || v != tt.version || r != tt.release {
+			t.Errorf("parseEVR(%q) = %q, %q, %q, want %q, %q, %q", tt.evr, e, v, r, tt.epoch, tt.version, tt.release)
+		}
+	}
+}
+
+func TestParseDepend(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Depend
+	}{
+		{"foo", Depend{Name: "foo"}},
+		{"foo>=1.2-3: desc", Depend{Name: "foo", Op: ">=", Version: "1.2-3", Description: "desc"}},
+		{"foo: for the foo support", Depend{Name: "foo", Description: "for the foo support"}},
+		{"foo:", Depend{Name: "foo"}},
+		{"foo=", Depend{Name: "foo", Op: "="}},
+		{"libfoo.so=1-64", Depend{Name: "libfoo.so", Op: "=", Version: "1-64"}},
+		{"foo<2", Depend{Name: "foo", Op: "<", Version: "2"}},
+		{"foo<=2", Depend{Name: "foo", Op: "<=", Version: "2"}},
+		{"foo>1:2.0", Depend{Name: "foo", Op: ">", Version: "1:2.0"}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseDepend(tt.s); got != tt.want {
+			t.Errorf("ParseDepend(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfiedBy(t *testing.T) {
+	tests := []struct {
+		depend, version string
+		want            bool
+	}{
+		{"foo", "", true},
+		{"foo", "1.0-1", true},
+		{"foo>=1.2", "", false},
+		{"foo>=1.2", "1.2-1", true},
+		{"foo>=1.2", "1.1-9", false},
+		{"foo=1.2", "1.2-5", true},
+		{"foo=1.2-2", "1.2-1", false},
+		{"foo=1.2-1", "1.2-1", true},
+		{"foo<2", "1:1.0-1", false},
+		{"foo<2", "1.9-1", true},
+		{"foo>1.0", "1.0-1", false},
+		{"foo>1", "1.0-1", true},
+		{"foo<=1.0", "1.0-3", true},
+		{"libfoo.so=1-64", "1-64", true},
+		{"libfoo.so=1-64", "1-32", false},
+	}
+
+	for _, tt := range tests {
+		if got := ParseDepend(tt.depend).SatisfiedBy(tt.version); got != tt.want {
+			t.Errorf("%s satisfied by %q = %v, want %v", tt.depend, tt.version, got, tt.want)
+		}
+	}
+}
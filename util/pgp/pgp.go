@@ -0,0 +1,185 @@
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Status of a signature
+const (
+	Valid      = "valid"       // signed by a key of the keyring
+	Invalid    = "invalid"     // bad signature or expired/revoked key
+	UnknownKey = "unknown_key" // signed by a key which is not in the keyring
+	Unsigned   = "unsigned"    // no signature
+	NoFile     = "no_file"     // signed file not found
+)
+
+// Signature is the result of the verification of a signature.
+type Signature struct {
+	Status      string
+	Fingerprint string // Fingerprint of the signer (or the issuer if the key is unknown)
+	Error       error
+}
+
+// Keyring is a set of public keys.
+type Keyring struct {
+	entities openpgp.EntityList
+}
+
+var keyExtensions = map[string]bool{
+	".asc": true,
+	".gpg": true,
+	".pgp": true,
+	".key": true,
+}
+
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN"))
+}
+
+func readKeys(data []byte) (openpgp.EntityList, error) {
+	if isArmored(data) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// LoadKeyring reads the public keys (armored or binary)
+// of the files of the directory with the extension
+// .asc, .gpg, .pgp or .key.
+func LoadKeyring(dir string) (k *Keyring, err error) {
+	var files []os.DirEntry
+	if files, err = os.ReadDir(dir); err != nil {
+		return
+	}
+
+	k = new(Keyring)
+	for _, f := range files {
+		if f.IsDir() || !keyExtensions[strings.ToLower(filepath.Ext(f.Name()))] {
+			continue
+		}
+
+		fp := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+
+		entities, err := readKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fp, err)
+		}
+		k.entities = append(k.entities, entities...)
+	}
+
+	if len(k.entities) == 0 {
+		return nil, errors.New("No public key found in " + dir)
+	}
+
+	return
+}
+
+// Len returns the number of keys of the keyring.
+func (k *Keyring) Len() int {
+	if k == nil {
+		return 0
+	}
+
+	return len(k.entities)
+}
+
+func fingerprint(fp []byte) string {
+	return strings.ToUpper(fmt.Sprintf("%x", fp))
+}
+
+// issuer returns the fingerprint (or the key ID)
+// of the issuer of the signature.
+func issuer(sig []byte) string {
+	p, err := packet.NewReader(bytes.NewReader(sig)).Next()
+	if err != nil {
+		return ""
+	}
+
+	s, ok := p.(*packet.Signature)
+	if !ok {
+		return ""
+	}
+	if len(s.IssuerFingerprint) > 0 {
+		return fingerprint(s.IssuerFingerprint)
+	}
+	if s.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *s.IssuerKeyId)
+	}
+
+	return ""
+}
+
+// Verify checks the detached signature (armored or binary)
+// of the signed content.
+func (k *Keyring) Verify(signed io.Reader, sig []byte) (s Signature) {
+	if len(sig) == 0 {
+		s.Status = Unsigned
+		return
+	}
+
+	if isArmored(sig) {
+		block, err := armor.Decode(bytes.NewReader(sig))
+		if err != nil {
+			s.Status, s.Error = Invalid, err
+			return
+		}
+		if sig, err = io.ReadAll(block.Body); err != nil {
+			s.Status, s.Error = Invalid, err
+			return
+		}
+	}
+
+	var entities openpgp.EntityList
+	if k != nil {
+		entities = k.entities
+	}
+
+	signer, err := openpgp.CheckDetachedSignature(entities, signed, bytes.NewReader(sig), nil)
+	switch {
+	case err == nil:
+		s.Status, s.Fingerprint = Valid, fingerprint(signer.PrimaryKey.Fingerprint)
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		s.Status, s.Fingerprint, s.Error = UnknownKey, issuer(sig), err
+	default:
+		s.Status, s.Fingerprint, s.Error = Invalid, issuer(sig), err
+		if signer != nil {
+			s.Fingerprint = fingerprint(signer.PrimaryKey.Fingerprint)
+		}
+	}
+
+	return
+}
+
+// VerifyFile checks the detached signature of the file.
+// The signature is read from the file <filePath>.sig if it exists,
+// or from the given signature otherwise.
+func (k *Keyring) VerifyFile(filePath string, sig []byte) (s Signature) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		s.Status, s.Error = NoFile, err
+		return
+	}
+	defer f.Close()
+
+	if data, err := os.ReadFile(filePath + ".sig"); err == nil {
+		sig = data
+	}
+
+	return k.Verify(f, sig)
+}
@@ -0,0 +1,200 @@
+package pgp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+var testConfig = &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}
+
+func newEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+
+	e, err := openpgp.NewEntity(name, "", name+"@example.net", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+// writeKey writes the public key of the entity, armored or binary.
+func writeKey(t *testing.T, fp string, e *openpgp.Entity, armored bool) {
+	t.Helper()
+
+	var b bytes.Buffer
+	if armored {
+		w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Serialize(w); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+	} else if err := e.Serialize(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(fp, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sign(t *testing.T, e *openpgp.Entity, content string, armored bool) []byte {
+	t.Helper()
+
+	var (
+		b   bytes.Buffer
+		err error
+	)
+	if armored {
+		err = openpgp.ArmoredDetachSign(&b, e, strings.NewReader(content), testConfig)
+	} else {
+		err = openpgp.DetachSign(&b, e, strings.NewReader(content), testConfig)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func loadTestKeyring(t *testing.T, entities ...*openpgp.Entity) *Keyring {
+	t.Helper()
+
+	dir := t.TempDir()
+	for i, e := range entities {
+		ext := ".asc"
+		if i%2 == 1 {
+			ext = ".gpg"
+		}
+		writeKey(t, filepath.Join(dir, e.PrimaryIdentity().UserId.Name+ext), e, ext == ".asc")
+	}
+	// Files without a key extension are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := LoadKeyring(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestLoadKeyring(t *testing.T) {
+	k := loadTestKeyring(t, newEntity(t, "armored"), newEntity(t, "binary"))
+	if k.Len() != 2 {
+		t.Errorf("got %d keys, want 2", k.Len())
+	}
+
+	if _, err := LoadKeyring(t.TempDir()); err == nil {
+		t.Error("LoadKeyring() of an empty directory: no error")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.asc"), []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyring(dir); err == nil {
+		t.Error("LoadKeyring() of a broken key: no error")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const content = "package content"
+
+	var (
+		armoredSigner = newEntity(t, "armored")
+		binarySigner  = newEntity(t, "binary")
+		unknown       = newEntity(t, "unknown")
+		k             = loadTestKeyring(t, armoredSigner, binarySigner)
+	)
+
+	tests := []struct {
+		name        string
+		content     string
+		sig         []byte
+		status      string
+		fingerprint []byte
+	}{
+		{"valid armored", content, sign(t, armoredSigner, content, true), Valid, armoredSigner.PrimaryKey.Fingerprint},
+		{"valid binary", content, sign(t, binarySigner, content, false), Valid, binarySigner.PrimaryKey.Fingerprint},
+		{"armored key binary signature", content, sign(t, armoredSigner, content, false), Valid, armoredSigner.PrimaryKey.Fingerprint},
+		{"tampered", content + ".", sign(t, binarySigner, content, false), Invalid, binarySigner.PrimaryKey.Fingerprint},
+		{"tampered armored", content + ".", sign(t, armoredSigner, content, true), Invalid, armoredSigner.PrimaryKey.Fingerprint},
+		{"unknown key", content, sign(t, unknown, content, false), UnknownKey, unknown.PrimaryKey.Fingerprint},
+		{"unknown key armored", content, sign(t, unknown, content, true), UnknownKey, unknown.PrimaryKey.Fingerprint},
+		{"unsigned", content, nil, Unsigned, nil},
+		{"garbage", content, []byte("-----BEGIN PGP SIGNATURE-----\nbroken"), Invalid, nil},
+	}
+
+	for _, tt := range tests {
+		s := k.Verify(strings.NewReader(tt.content), tt.sig)
+		if s.Status != tt.status {
+			t.Errorf("%s: status = %s, want %s (%v)", tt.name, s.Status, tt.status, s.Error)
+		}
+		if want := fingerprint(tt.fingerprint); s.Fingerprint != want {
+			t.Errorf("%s: fingerprint = %q, want %q", tt.name, s.Fingerprint, want)
+		}
+		if (s.Status == Valid || s.Status == Unsigned) != (s.Error == nil) {
+			t.Errorf("%s: unexpected error %v", tt.name, s.Error)
+		}
+	}
+
+	// Without keyring, every signature is signed by an unknown key.
+	var none *Keyring
+	if s := none.Verify(strings.NewReader(content), sign(t, armoredSigner, content, false)); s.Status != UnknownKey {
+		t.Errorf("without keyring: status = %s, want %s", s.Status, UnknownKey)
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	const content = "package content"
+
+	var (
+		signer  = newEntity(t, "signer")
+		unknown = newEntity(t, "unknown")
+		k       = loadTestKeyring(t, signer)
+		dir     = t.TempDir()
+		pkg     = filepath.Join(dir, "pkg-1.0-1-x86_64.pkg.tar.zst")
+	)
+	if err := os.WriteFile(pkg, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without <file>.sig, the given signature (%PGPSIG%) is checked.
+	if s := k.VerifyFile(pkg, sign(t, signer, content, false)); s.Status != Valid {
+		t.Errorf("%%PGPSIG%%: status = %s, want %s (%v)", s.Status, Valid, s.Error)
+	}
+	if s := k.VerifyFile(pkg, nil); s.Status != Unsigned {
+		t.Errorf("no signature: status = %s, want %s", s.Status, Unsigned)
+	}
+
+	// <file>.sig takes precedence over %PGPSIG%.
+	if err := os.WriteFile(pkg+".sig", sign(t, signer, content, false), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s := k.VerifyFile(pkg, sign(t, unknown, content, false)); s.Status != Valid {
+		t.Errorf("<file>.sig: status = %s, want %s (%v)", s.Status, Valid, s.Error)
+	}
+	if err := os.WriteFile(pkg+".sig", sign(t, unknown, content, true), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s := k.VerifyFile(pkg, sign(t, signer, content, false)); s.Status != UnknownKey {
+		t.Errorf("<file>.sig of an unknown key: status = %s, want %s", s.Status, UnknownKey)
+	}
+
+	if s := k.VerifyFile(filepath.Join(dir, "missing.pkg.tar.zst"), sign(t, signer, content, false)); s.Status != NoFile || s.Error == nil {
+		t.Errorf("missing file: status = %s, want %s", s.Status, NoFile)
+	}
+}
@@ -2,13 +2,16 @@ package resource
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"errors"
 	"io"
-	"strings"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
 )
 
 type FakeReadCloser struct {
@@ -21,54 +24,136 @@ func ReadGZ(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
 
 func ReadZST(r io.Reader) (io.ReadCloser, error) {
 	rc, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return rc.IOReadCloser(), nil
+}
+
+func ReadXZ(r io.Reader) (io.ReadCloser, error) {
+	rc, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
 
-	return rc.IOReadCloser(), err
+	return FakeReadCloser{rc}, nil
 }
 
+func ReadBZ2(r io.Reader) (io.ReadCloser, error) { return FakeReadCloser{bzip2.NewReader(r)}, nil }
+
+func ReadLZ4(r io.Reader) (io.ReadCloser, error) { return FakeReadCloser{lz4.NewReader(r)}, nil }
+
+func ReadRaw(r io.Reader) (io.ReadCloser, error) { return FakeReadCloser{r}, nil }
+
 func ReadTAR(r io.Reader) *tar.Reader { return tar.NewReader(r) }
 
+// readCloser is a reader which closes
+// all the underlying readers on close.
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r readCloser) Close() (err error) {
+	for _, c := range r.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// Archive is a streamed tar archive.
+// It must be closed after use.
+type Archive struct {
+	*tar.Reader
+	rc io.Closer
+}
+
+func (a *Archive) Close() error { return a.rc.Close() }
+
+type compression struct {
+	offset int
+	magic  []byte
+	dec    func(io.Reader) (io.ReadCloser, error)
+}
+
+var compressions = []compression{
+	{0, []byte{0x1f, 0x8b}, ReadGZ},
+	{0, []byte{0x28, 0xb5, 0x2f, 0xfd}, ReadZST},
+	{0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, ReadXZ},
+	{0, []byte{'B', 'Z', 'h'}, ReadBZ2},
+	{0, []byte{0x04, 0x22, 0x4d, 0x18}, ReadLZ4},
+	{257, []byte("ustar"), ReadRaw}, // uncompressed tar
+}
+
+// Detect returns the decompressor of the stream
+// by reading its header.
+func Detect(r *bufio.Reader) (dec func(io.Reader) (io.ReadCloser, error), err error) {
+	for _, c := range compressions {
+		var header []byte
+		if header, err = r.Peek(c.offset + len(c.magic)); err != nil {
+			if err != io.EOF && err != bufio.ErrBufferFull {
+				return
+			}
+			err = nil
+			continue
+		}
+		if bytes.Equal(header[c.offset:], c.magic) {
+			return c.dec, nil
+		}
+	}
+
+	return nil, errors.New("Unsupported compression format")
+}
+
+// Decompress opens the resource and decompresses it on the fly
+// using the compression format detected from its header.
+// The caller must close the returned reader.
 func Decompress(uri string) (rc io.ReadCloser, err error) {
-	var dec func(io.Reader) (io.ReadCloser, error)
-
-	switch {
-	case strings.HasSuffix(uri, ".gz"):
-		dec = ReadGZ
-	case strings.HasSuffix(uri, "zst"):
-		dec = ReadZST
-	default:
-		err = errors.New("Unsupported compression format")
+	var f io.ReadCloser
+	if f, err = Open(uri); err != nil {
 		return
 	}
 
-	var r io.Reader
-	if r, err = Open(uri); err != nil {
+	br := bufio.NewReader(f)
+	var (
+		dec func(io.Reader) (io.ReadCloser, error)
+		d   io.ReadCloser
+	)
+	if dec, err = Detect(br); err == nil {
+		d, err = dec(br)
+	}
+	if err != nil {
+		f.Close()
 		return
 	}
 
-	return dec(r)
+	return readCloser{
+		Reader:  d,
+		closers: []io.Closer{d, f},
+	}, nil
 }
 
-func OpenTAR(uri string) (tr *tar.Reader, err error) {
-	var r io.Reader
+func OpenTAR(uri string) (a *Archive, err error) {
+	var rc io.ReadCloser
 
-	if r, err = Open(uri); err == nil {
-		tr = ReadTAR(r)
+	if rc, err = Open(uri); err == nil {
+		a = &Archive{Reader: ReadTAR(rc), rc: rc}
 	}
 
 	return
 }
 
-func OpenArchive(uri string) (tr *tar.Reader, err error) {
+// OpenArchive opens a (compressed or not) tar archive
+// without loading it in memory.
+func OpenArchive(uri string) (a *Archive, err error) {
 	var rc io.ReadCloser
 
-	if rc, err = Decompress(uri); err != nil {
-		return
-	}
-	defer rc.Close()
-
-	r := new(bytes.Buffer)
-	if _, err = io.Copy(r, rc); err == nil {
-		tr = ReadTAR(r)
+	if rc, err = Decompress(uri); err == nil {
+		a = &Archive{Reader: ReadTAR(rc), rc: rc}
 	}
 
 	return
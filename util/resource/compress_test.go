@@ -0,0 +1,206 @@
+package resource
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	testEntry   = "hello-1.0-1/desc"
+	testContent = "%NAME%\nhello\n"
+)
+
+// testTar returns a tar archive containing one entry.
+// testdata/archive.tar.bz2 is this archive compressed with bzip2.
+func testTar(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	hdr := &tar.Header{
+		Name:     testEntry,
+		Mode:     0644,
+		Size:     int64(len(testContent)),
+		ModTime:  time.Unix(1700000000, 0),
+		Typeflag: tar.TypeReg,
+		Format:   tar.FormatUSTAR,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(testContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func compressWith(t *testing.T, data []byte, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeTemp(t *testing.T, data []byte) string {
+	t.Helper()
+
+	f := filepath.Join(t.TempDir(), "repo.db")
+	if err := os.WriteFile(f, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func sameFunc(f1, f2 func(io.Reader) (io.ReadCloser, error)) bool {
+	return reflect.ValueOf(f1).Pointer() == reflect.ValueOf(f2).Pointer()
+}
+
+func TestDetect(t *testing.T) {
+	archive := testTar(t)
+	bz2, err := os.ReadFile(filepath.Join("testdata", "archive.tar.bz2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want func(io.Reader) (io.ReadCloser, error)
+	}{
+		{"gzip", compressWith(t, archive, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }), ReadGZ},
+		{"zstd", compressWith(t, archive, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }), ReadZST},
+		{"xz", compressWith(t, archive, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }), ReadXZ},
+		{"bzip2", bz2, ReadBZ2},
+		{"lz4", compressWith(t, archive, func(w io.Writer) (io.WriteCloser, error) { return lz4.NewWriter(w), nil }), ReadLZ4},
+		{"ustar", archive, ReadRaw},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := Detect(bufio.NewReader(bytes.NewReader(tt.data)))
+			if err != nil {
+				t.Fatalf("Detect: %s", err)
+			}
+			if !sameFunc(dec, tt.want) {
+				t.Fatalf("Detect returned the wrong decompressor")
+			}
+
+			uri := writeTemp(t, tt.data)
+
+			rc, err := Decompress(uri)
+			if err != nil {
+				t.Fatalf("Decompress: %s", err)
+			}
+			got, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("Decompress: %s", err)
+			}
+			if !bytes.Equal(got, archive) {
+				t.Errorf("Decompress: got %d bytes, want the %d bytes of the archive", len(got), len(archive))
+			}
+
+			a, err := OpenArchive(uri)
+			if err != nil {
+				t.Fatalf("OpenArchive: %s", err)
+			}
+			defer a.Close()
+			hdr, err := a.Next()
+			if err != nil {
+				t.Fatalf("OpenArchive: %s", err)
+			}
+			content, err := io.ReadAll(a)
+			if err != nil {
+				t.Fatalf("OpenArchive: %s", err)
+			}
+			if hdr.Name != testEntry || string(content) != testContent {
+				t.Errorf("OpenArchive: got entry %q = %q, want %q = %q", hdr.Name, content, testEntry, testContent)
+			}
+			if _, err := a.Next(); err != io.EOF {
+				t.Errorf("OpenArchive: got %v after the last entry, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestDetectUnsupported(t *testing.T) {
+	archive := testTar(t)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"plain", []byte("%FILES%\nusr/bin/hello\n")},
+		{"short gzip header", []byte{0x1f}},
+		{"short xz header", []byte{0xfd, '7', 'z', 'X'}},
+		{"truncated tar header", archive[:260]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Detect(bufio.NewReader(bytes.NewReader(tt.data))); err == nil {
+				t.Errorf("Detect: got no error")
+			}
+
+			uri := writeTemp(t, tt.data)
+			if rc, err := Decompress(uri); err == nil {
+				rc.Close()
+				t.Errorf("Decompress: got no error")
+			}
+			if a, err := OpenArchive(uri); err == nil {
+				a.Close()
+				t.Errorf("OpenArchive: got no error")
+			}
+		})
+	}
+}
+
+func TestTruncatedStream(t *testing.T) {
+	archive := testTar(t)
+	gz := compressWith(t, archive, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+
+	a, err := OpenArchive(writeTemp(t, gz[:len(gz)/2]))
+	if err != nil {
+		t.Fatalf("OpenArchive: %s", err)
+	}
+	defer a.Close()
+
+	if _, err := a.Next(); err == nil {
+		_, err = io.ReadAll(a)
+		if err == nil {
+			_, err = a.Next()
+		}
+		if err == nil || err == io.EOF {
+			t.Errorf("reading a truncated archive: got %v, want an error", err)
+		}
+	}
+}
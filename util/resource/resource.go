@@ -1,7 +1,7 @@
 package resource
 
 import (
-	"bytes"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -10,8 +10,25 @@ import (
 	"time"
 )
 
+// Timeout of the whole transfer of a remote resource
+const transferTimeout = 5 * time.Minute
+
 var (
 	TimeoutInSeconds time.Duration = 10
+
+	// client opens the remote resources.
+	// The connection and the response headers are limited to TimeoutInSeconds.
+	client = &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout: TimeoutInSeconds * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   TimeoutInSeconds * time.Second,
+			ResponseHeaderTimeout: TimeoutInSeconds * time.Second,
+		},
+		Timeout: transferTimeout,
+	}
 )
 
 func IsURL(uri string) bool {
@@ -66,27 +83,27 @@ func IsDir(uri string) bool {
 	return false
 }
 
-func Open(uri string) (data io.Reader, err error) {
-	var f io.ReadCloser
-
-	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+// Open opens the resource (file path or URL) for streaming.
+// The caller must close the returned reader.
+func Open(uri string) (rc io.ReadCloser, err error) {
+	if IsURL(uri) {
 		var resp *http.Response
-		if resp, err = http.Get(uri); err == nil {
-			f = resp.Body
+		if resp, err = client.Get(uri); err != nil {
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("[%d] %s", resp.StatusCode, resp.Status)
 		}
-	} else {
-		f, err = os.Open(uri)
+		return resp.Body, nil
 	}
 
-	if err == nil {
-		defer f.Close()
-		var buf bytes.Buffer
-		if _, err = io.Copy(&buf, f); err == nil {
-			data = &buf
-		}
+	var f *os.File
+	if f, err = os.Open(uri); err != nil {
+		return
 	}
 
-	return
+	return f, nil
 }
 
 func IsPortOpen(host, port string) bool {
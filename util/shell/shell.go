@@ -76,3 +76,42 @@ func GetBool(question string, defaultValue bool) bool {
 
 	return defaultValue
 }
+
+// GetRange returns the indexes (starting from 0)
+// selected by the range argument, for a list of c elements.
+// The range argument is either "all" or a list of positions
+// (starting from 1) and intervals separated by comma (eg. 1,4-5,18,2).
+func GetRange(arg string, c int) (rg []int) {
+	if arg == "all" {
+		rg = make([]int, c)
+		for i := range rg {
+			rg[i] = i
+		}
+		return
+	}
+
+	srg := strings.Split(arg, ",")
+	for _, e := range srg {
+		r := strings.SplitN(e, "-", 2)
+		if len(r) == 1 {
+			if i, err := strconv.Atoi(r[0]); err == nil && i > 0 && i <= c {
+				rg = append(rg, i-1)
+			}
+		} else {
+			i1, e1 := strconv.Atoi(r[0])
+			i2, e2 := strconv.Atoi(r[1])
+			if e1 == nil && e2 == nil {
+				if i1 > i2 {
+					i1, i2 = i2, i1
+				}
+				for i := i1; i <= i2; i++ {
+					if i > 0 && i <= c {
+						rg = append(rg, i-1)
+					}
+				}
+			}
+		}
+	}
+
+	return
+}
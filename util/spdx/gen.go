@@ -0,0 +1,110 @@
+//go:build ignore
+
+// gen generates list.go from the JSON files of the SPDX license list
+// (https://github.com/spdx/license-list-data).
+//
+// Usage: go run gen.go [-licenses <licenses.json>] [-exceptions <exceptions.json>]
+// The files can be local paths or URLs (default: the latest release).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"pmanager/util/resource"
+	"sort"
+	"strings"
+)
+
+const baseURL = "https://raw.githubusercontent.com/spdx/license-list-data/main/json/"
+
+type licenseList struct {
+	Version  string `json:"licenseListVersion"`
+	Licenses []struct {
+		ID         string `json:"licenseId"`
+		Deprecated bool   `json:"isDeprecatedLicenseId"`
+	} `json:"licenses"`
+}
+
+type exceptionList struct {
+	Version    string `json:"licenseListVersion"`
+	Exceptions []struct {
+		ID         string `json:"licenseExceptionId"`
+		Deprecated bool   `json:"isDeprecatedLicenseId"`
+	} `json:"exceptions"`
+}
+
+func read(uri string, v any) {
+	f, err := resource.Open(uri)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", uri, err)
+		os.Exit(1)
+	}
+}
+
+func writeMap(b *bytes.Buffer, name, comment string, ids map[string]bool) {
+	keys := make([]string, 0, len(ids))
+	for id := range ids {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "\n// %s\nvar %s = map[string]bool{\n", comment, name)
+	for _, id := range keys {
+		fmt.Fprintf(b, "%q: %t,\n", id, ids[id])
+	}
+	b.WriteString("}\n")
+}
+
+func main() {
+	licensesURI := flag.String("licenses", baseURL+"licenses.json", "licenses.json of the SPDX license list")
+	exceptionsURI := flag.String("exceptions", baseURL+"exceptions.json", "exceptions.json of the SPDX license list")
+	output := flag.String("o", "list.go", "generated file")
+	flag.Parse()
+
+	var (
+		ll licenseList
+		el exceptionList
+	)
+	read(*licensesURI, &ll)
+	read(*exceptionsURI, &el)
+	if ll.Version != el.Version {
+		fmt.Fprintf(os.Stderr, "Version mismatch: licenses %s, exceptions %s\n", ll.Version, el.Version)
+		os.Exit(1)
+	}
+
+	licenses, exceptions := make(map[string]bool), make(map[string]bool)
+	for _, l := range ll.Licenses {
+		licenses[strings.ToLower(l.ID)] = l.Deprecated
+	}
+	for _, e := range el.Exceptions {
+		exceptions[strings.ToLower(e.ID)] = e.Deprecated
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen.go from the SPDX license list %s. DO NOT EDIT.\n\n", ll.Version)
+	b.WriteString("package spdx\n\n")
+	b.WriteString("// ListVersion is the version of the SPDX license list.\n")
+	fmt.Fprintf(&b, "const ListVersion = %q\n", ll.Version)
+	writeMap(&b, "licenses", "Identifiers (lowercased) of the SPDX license list, with their deprecated flag", licenses)
+	writeMap(&b, "exceptions", "Identifiers (lowercased) of the SPDX exception list, with their deprecated flag", exceptions)
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,792 @@
+// Code generated by gen.go from the SPDX license list 3.27.0. DO NOT EDIT.
+
+package spdx
+
+// ListVersion is the version of the SPDX license list.
+const ListVersion = "3.27.0"
+
+// Identifiers (lowercased) of the SPDX license list, with their deprecated flag
+var licenses = map[string]bool{
+	"0bsd":                                 false,
+	"3d-slicer-1.0":                        false,
+	"aal":                                  false,
+	"abstyles":                             false,
+	"adacore-doc":                          false,
+	"adobe-2006":                           false,
+	"adobe-display-postscript":             false,
+	"adobe-glyph":                          false,
+	"adobe-utopia":                         false,
+	"adsl":                                 false,
+	"afl-1.1":                              false,
+	"afl-1.2":                              false,
+	"afl-2.0":                              false,
+	"afl-2.1":                              false,
+	"afl-3.0":                              false,
+	"afmparse":                             false,
+	"agpl-1.0":                             true,
+	"agpl-1.0-only":                        false,
+	"agpl-1.0-or-later":                    false,
+	"agpl-3.0":                             true,
+	"agpl-3.0-only":                        false,
+	"agpl-3.0-or-later":                    false,
+	"aladdin":                              false,
+	"amd-newlib":                           false,
+	"amdplpa":                              false,
+	"aml":                                  false,
+	"aml-glslang":                          false,
+	"ampas":                                false,
+	"antlr-pd":                             false,
+	"antlr-pd-fallback":                    false,
+	"any-osi":                              false,
+	"any-osi-perl-modules":                 false,
+	"apache-1.0":                           false,
+	"apache-1.1":                           false,
+	"apache-2.0":                           false,
+	"apafml":                               false,
+	"apl-1.0":                              false,
+	"app-s2p":                              false,
+	"apsl-1.0":                             false,
+	"apsl-1.1":                             false,
+	"apsl-1.2":                             false,
+	"apsl-2.0":                             false,
+	"arphic-1999":                          false,
+	"artistic-1.0":                         false,
+	"artistic-1.0-cl8":                     false,
+	"artistic-1.0-perl":                    false,
+	"artistic-2.0":                         false,
+	"artistic-dist":                        false,
+	"aspell-ru":                            false,
+	"aswf-digital-assets-1.0":              false,
+	"aswf-digital-assets-1.1":              false,
+	"baekmuk":                              false,
+	"bahyph":                               false,
+	"barr":                                 false,
+	"bcrypt-solar-designer":                false,
+	"beerware":                             false,
+	"bitstream-charter":                    false,
+	"bitstream-vera":                       false,
+	"bittorrent-1.0":                       false,
+	"bittorrent-1.1":                       false,
+	"blessing":                             false,
+	"blueoak-1.0.0":                        false,
+	"boehm-gc":                             false,
+	"boehm-gc-without-fee":                 false,
+	"borceux":                              false,
+	"brian-gladman-2-clause":               false,
+	"brian-gladman-3-clause":               false,
+	"bsd-1-clause":                         false,
+	"bsd-2-clause":                         false,
+	"bsd-2-clause-darwin":                  false,
+	"bsd-2-clause-first-lines":             false,
+	"bsd-2-clause-freebsd":                 true,
+	"bsd-2-clause-netbsd":                  true,
+	"bsd-2-clause-patent":                  false,
+	"bsd-2-clause-pkgconf-disclaimer":      false,
+	"bsd-2-clause-views":                   false,
+	"bsd-3-clause":                         false,
+	"bsd-3-clause-acpica":                  false,
+	"bsd-3-clause-attribution":             false,
+	"bsd-3-clause-clear":                   false,
+	"bsd-3-clause-flex":                    false,
+	"bsd-3-clause-hp":                      false,
+	"bsd-3-clause-lbnl":                    false,
+	"bsd-3-clause-modification":            false,
+	"bsd-3-clause-no-military-license":     false,
+	"bsd-3-clause-no-nuclear-license":      false,
+	"bsd-3-clause-no-nuclear-license-2014": false,
+	"bsd-3-clause-no-nuclear-warranty":     false,
+	"bsd-3-clause-open-mpi":                false,
+	"bsd-3-clause-sun":                     false,
+	"bsd-4-clause":                         false,
+	"bsd-4-clause-shortened":               false,
+	"bsd-4-clause-uc":                      false,
+	"bsd-4.3reno":                          false,
+	"bsd-4.3tahoe":                         false,
+	"bsd-advertising-acknowledgement":      false,
+	"bsd-attribution-hpnd-disclaimer":      false,
+	"bsd-inferno-nettverk":                 false,
+	"bsd-protection":                       false,
+	"bsd-source-beginning-file":            false,
+	"bsd-source-code":                      false,
+	"bsd-systemics":                        false,
+	"bsd-systemics-w3works":                false,
+	"bsl-1.0":                              false,
+	"busl-1.1":                             false,
+	"bzip2-1.0.5":                          true,
+	"bzip2-1.0.6":                          false,
+	"c-uda-1.0":                            false,
+	"cal-1.0":                              false,
+	"cal-1.0-combined-work-exception":      false,
+	"caldera":                              false,
+	"caldera-no-preamble":                  false,
+	"catharon":                             false,
+	"catosl-1.1":                           false,
+	"cc-by-1.0":                            false,
+	"cc-by-2.0":                            false,
+	"cc-by-2.5":                            false,
+	"cc-by-2.5-au":                         false,
+	"cc-by-3.0":                            false,
+	"cc-by-3.0-at":                         false,
+	"cc-by-3.0-au":                         false,
+	"cc-by-3.0-de":                         false,
+	"cc-by-3.0-igo":                        false,
+	"cc-by-3.0-nl":                         false,
+	"cc-by-3.0-us":                         false,
+	"cc-by-4.0":                            false,
+	"cc-by-nc-1.0":                         false,
+	"cc-by-nc-2.0":                         false,
+	"cc-by-nc-2.5":                         false,
+	"cc-by-nc-3.0":                         false,
+	"cc-by-nc-3.0-de":                      false,
+	"cc-by-nc-4.0":                         false,
+	"cc-by-nc-nd-1.0":                      false,
+	"cc-by-nc-nd-2.0":                      false,
+	"cc-by-nc-nd-2.5":                      false,
+	"cc-by-nc-nd-3.0":                      false,
+	"cc-by-nc-nd-3.0-de":                   false,
+	"cc-by-nc-nd-3.0-igo":                  false,
+	"cc-by-nc-nd-4.0":                      false,
+	"cc-by-nc-sa-1.0":                      false,
+	"cc-by-nc-sa-2.0":                      false,
+	"cc-by-nc-sa-2.0-de":                   false,
+	"cc-by-nc-sa-2.0-fr":                   false,
+	"cc-by-nc-sa-2.0-uk":                   false,
+	"cc-by-nc-sa-2.5":                      false,
+	"cc-by-nc-sa-3.0":                      false,
+	"cc-by-nc-sa-3.0-de":                   false,
+	"cc-by-nc-sa-3.0-igo":                  false,
+	"cc-by-nc-sa-4.0":                      false,
+	"cc-by-nd-1.0":                         false,
+	"cc-by-nd-2.0":                         false,
+	"cc-by-nd-2.5":                         false,
+	"cc-by-nd-3.0":                         false,
+	"cc-by-nd-3.0-de":                      false,
+	"cc-by-nd-4.0":                         false,
+	"cc-by-sa-1.0":                         false,
+	"cc-by-sa-2.0":                         false,
+	"cc-by-sa-2.0-uk":                      false,
+	"cc-by-sa-2.1-jp":                      false,
+	"cc-by-sa-2.5":                         false,
+	"cc-by-sa-3.0":                         false,
+	"cc-by-sa-3.0-at":                      false,
+	"cc-by-sa-3.0-de":                      false,
+	"cc-by-sa-3.0-igo":                     false,
+	"cc-by-sa-4.0":                         false,
+	"cc-pddc":                              false,
+	"cc-pdm-1.0":                           false,
+	"cc-sa-1.0":                            false,
+	"cc0-1.0":                              false,
+	"cddl-1.0":                             false,
+	"cddl-1.1":                             false,
+	"cdl-1.0":                              false,
+	"cdla-permissive-1.0":                  false,
+	"cdla-permissive-2.0":                  false,
+	"cdla-sharing-1.0":                     false,
+	"cecill-1.0":                           false,
+	"cecill-1.1":                           false,
+	"cecill-2.0":                           false,
+	"cecill-2.1":                           false,
+	"cecill-b":                             false,
+	"cecill-c":                             false,
+	"cern-ohl-1.1":                         false,
+	"cern-ohl-1.2":                         false,
+	"cern-ohl-p-2.0":                       false,
+	"cern-ohl-s-2.0":                       false,
+	"cern-ohl-w-2.0":                       false,
+	"cfitsio":                              false,
+	"check-cvs":                            false,
+	"checkmk":                              false,
+	"clartistic":                           false,
+	"clips":                                false,
+	"cmu-mach":                             false,
+	"cmu-mach-nodoc":                       false,
+	"cnri-jython":                          false,
+	"cnri-python":                          false,
+	"cnri-python-gpl-compatible":           false,
+	"coil-1.0":                             false,
+	"community-spec-1.0":                   false,
+	"condor-1.1":                           false,
+	"copyleft-next-0.3.0":                  false,
+	"copyleft-next-0.3.1":                  false,
+	"cornell-lossless-jpeg":                false,
+	"cpal-1.0":                             false,
+	"cpl-1.0":                              false,
+	"cpol-1.02":                            false,
+	"cronyx":                               false,
+	"crossword":                            false,
+	"cryptoswift":                          false,
+	"crystalstacker":                       false,
+	"cua-opl-1.0":                          false,
+	"cube":                                 false,
+	"curl":                                 false,
+	"cve-tou":                              false,
+	"d-fsl-1.0":                            false,
+	"dec-3-clause":                         false,
+	"diffmark":                             false,
+	"dl-de-by-2.0":                         false,
+	"dl-de-zero-2.0":                       false,
+	"doc":                                  false,
+	"docbook-dtd":                          false,
+	"docbook-schema":                       false,
+	"docbook-stylesheet":                   false,
+	"docbook-xml":                          false,
+	"dotseqn":                              false,
+	"drl-1.0":                              false,
+	"drl-1.1":                              false,
+	"dsdp":                                 false,
+	"dtoa":                                 false,
+	"dvipdfm":                              false,
+	"ecl-1.0":                              false,
+	"ecl-2.0":                              false,
+	"ecos-2.0":                             true,
+	"efl-1.0":                              false,
+	"efl-2.0":                              false,
+	"egenix":                               false,
+	"elastic-2.0":                          false,
+	"entessa":                              false,
+	"epics":                                false,
+	"epl-1.0":                              false,
+	"epl-2.0":                              false,
+	"erlpl-1.1":                            false,
+	"etalab-2.0":                           false,
+	"eudatagrid":                           false,
+	"eupl-1.0":                             false,
+	"eupl-1.1":                             false,
+	"eupl-1.2":                             false,
+	"eurosym":                              false,
+	"fair":                                 false,
+	"fbm":                                  false,
+	"fdk-aac":                              false,
+	"ferguson-twofish":                     false,
+	"frameworx-1.0":                        false,
+	"freebsd-doc":                          false,
+	"freeimage":                            false,
+	"fsfap":                                false,
+	"fsfap-no-warranty-disclaimer":         false,
+	"fsful":                                false,
+	"fsfullr":                              false,
+	"fsfullrsd":                            false,
+	"fsfullrwd":                            false,
+	"fsl-1.1-alv2":                         false,
+	"fsl-1.1-mit":                          false,
+	"ftl":                                  false,
+	"furuseth":                             false,
+	"fwlw":                                 false,
+	"game-programming-gems":                false,
+	"gcr-docs":                             false,
+	"gd":                                   false,
+	"generic-xts":                          false,
+	"gfdl-1.1":                             true,
+	"gfdl-1.1-invariants-only":             false,
+	"gfdl-1.1-invariants-or-later":         false,
+	"gfdl-1.1-no-invariants-only":          false,
+	"gfdl-1.1-no-invariants-or-later":      false,
+	"gfdl-1.1-only":                        false,
+	"gfdl-1.1-or-later":                    false,
+	"gfdl-1.2":                             true,
+	"gfdl-1.2-invariants-only":             false,
+	"gfdl-1.2-invariants-or-later":         false,
+	"gfdl-1.2-no-invariants-only":          false,
+	"gfdl-1.2-no-invariants-or-later":      false,
+	"gfdl-1.2-only":                        false,
+	"gfdl-1.2-or-later":                    false,
+	"gfdl-1.3":                             true,
+	"gfdl-1.3-invariants-only":             false,
+	"gfdl-1.3-invariants-or-later":         false,
+	"gfdl-1.3-no-invariants-only":          false,
+	"gfdl-1.3-no-invariants-or-later":      false,
+	"gfdl-1.3-only":                        false,
+	"gfdl-1.3-or-later":                    false,
+	"giftware":                             false,
+	"gl2ps":                                false,
+	"glide":                                false,
+	"glulxe":                               false,
+	"glwtpl":                               false,
+	"gnuplot":                              false,
+	"gpl-1.0":                              true,
+	"gpl-1.0+":                             true,
+	"gpl-1.0-only":                         false,
+	"gpl-1.0-or-later":                     false,
+	"gpl-2.0":                              true,
+	"gpl-2.0+":                             true,
+	"gpl-2.0-only":                         false,
+	"gpl-2.0-or-later":                     false,
+	"gpl-2.0-with-autoconf-exception":      true,
+	"gpl-2.0-with-bison-exception":         true,
+	"gpl-2.0-with-classpath-exception":     true,
+	"gpl-2.0-with-font-exception":          true,
+	"gpl-2.0-with-gcc-exception":           true,
+	"gpl-3.0":                              true,
+	"gpl-3.0+":                             true,
+	"gpl-3.0-only":                         false,
+	"gpl-3.0-or-later":                     false,
+	"gpl-3.0-with-autoconf-exception":      true,
+	"gpl-3.0-with-gcc-exception":           true,
+	"graphics-gems":                        false,
+	"gsoap-1.3b":                           false,
+	"gtkbook":                              false,
+	"gutmann":                              false,
+	"haskellreport":                        false,
+	"hdf5":                                 false,
+	"hdparm":                               false,
+	"hidapi":                               false,
+	"hippocratic-2.1":                      false,
+	"hp-1986":                              false,
+	"hp-1989":                              false,
+	"hpnd":                                 false,
+	"hpnd-dec":                             false,
+	"hpnd-doc":                             false,
+	"hpnd-doc-sell":                        false,
+	"hpnd-export-us":                       false,
+	"hpnd-export-us-acknowledgement":       false,
+	"hpnd-export-us-modify":                false,
+	"hpnd-export2-us":                      false,
+	"hpnd-fenneberg-livingston":            false,
+	"hpnd-inria-imag":                      false,
+	"hpnd-intel":                           false,
+	"hpnd-kevlin-henney":                   false,
+	"hpnd-markus-kuhn":                     false,
+	"hpnd-merchantability-variant":         false,
+	"hpnd-mit-disclaimer":                  false,
+	"hpnd-netrek":                          false,
+	"hpnd-pbmplus":                         false,
+	"hpnd-sell-mit-disclaimer-xserver":     false,
+	"hpnd-sell-regexpr":                    false,
+	"hpnd-sell-variant":                    false,
+	"hpnd-sell-variant-mit-disclaimer":     false,
+	"hpnd-sell-variant-mit-disclaimer-rev": false,
+	"hpnd-uc":                              false,
+	"hpnd-uc-export-us":                    false,
+	"htmltidy":                             false,
+	"ibm-pibs":                             false,
+	"icu":                                  false,
+	"iec-code-components-eula":             false,
+	"ijg":                                  false,
+	"ijg-short":                            false,
+	"imagemagick":                          false,
+	"imatix":                               false,
+	"imlib2":                               false,
+	"info-zip":                             false,
+	"inner-net-2.0":                        false,
+	"innosetup":                            false,
+	"intel":                                false,
+	"intel-acpi":                           false,
+	"interbase-1.0":                        false,
+	"ipa":                                  false,
+	"ipl-1.0":                              false,
+	"isc":                                  false,
+	"isc-veillard":                         false,
+	"jam":                                  false,
+	"jasper-2.0":                           false,
+	"jove":                                 false,
+	"jpl-image":                            false,
+	"jpnic":                                false,
+	"json":                                 false,
+	"kastrup":                              false,
+	"kazlib":                               false,
+	"knuth-ctan":                           false,
+	"lal-1.2":                              false,
+	"lal-1.3":                              false,
+	"latex2e":                              false,
+	"latex2e-translated-notice":            false,
+	"leptonica":                            false,
+	"lgpl-2.0":                             true,
+	"lgpl-2.0+":                            true,
+	"lgpl-2.0-only":                        false,
+	"lgpl-2.0-or-later":                    false,
+	"lgpl-2.1":                             true,
+	"lgpl-2.1+":                            true,
+	"lgpl-2.1-only":                        false,
+	"lgpl-2.1-or-later":                    false,
+	"lgpl-3.0":                             true,
+	"lgpl-3.0+":                            true,
+	"lgpl-3.0-only":                        false,
+	"lgpl-3.0-or-later":                    false,
+	"lgpllr":                               false,
+	"libpng":                               false,
+	"libpng-1.6.35":                        false,
+	"libpng-2.0":                           false,
+	"libselinux-1.0":                       false,
+	"libtiff":                              false,
+	"libutil-david-nugent":                 false,
+	"liliq-p-1.1":                          false,
+	"liliq-r-1.1":                          false,
+	"liliq-rplus-1.1":                      false,
+	"linux-man-pages-1-para":               false,
+	"linux-man-pages-copyleft":             false,
+	"linux-man-pages-copyleft-2-para":      false,
+	"linux-man-pages-copyleft-var":         false,
+	"linux-openib":                         false,
+	"loop":                                 false,
+	"lpd-document":                         false,
+	"lpl-1.0":                              false,
+	"lpl-1.02":                             false,
+	"lppl-1.0":                             false,
+	"lppl-1.1":                             false,
+	"lppl-1.2":                             false,
+	"lppl-1.3a":                            false,
+	"lppl-1.3c":                            false,
+	"lsof":                                 false,
+	"lucida-bitmap-fonts":                  false,
+	"lzma-sdk-9.11-to-9.20":                false,
+	"lzma-sdk-9.22":                        false,
+	"mackerras-3-clause":                   false,
+	"mackerras-3-clause-acknowledgment":    false,
+	"magaz":                                false,
+	"mailprio":                             false,
+	"makeindex":                            false,
+	"man2html":                             false,
+	"martin-birgmeier":                     false,
+	"mcphee-slideshow":                     false,
+	"metamail":                             false,
+	"minpack":                              false,
+	"mips":                                 false,
+	"miros":                                false,
+	"mit":                                  false,
+	"mit-0":                                false,
+	"mit-advertising":                      false,
+	"mit-click":                            false,
+	"mit-cmu":                              false,
+	"mit-enna":                             false,
+	"mit-feh":                              false,
+	"mit-festival":                         false,
+	"mit-khronos-old":                      false,
+	"mit-modern-variant":                   false,
+	"mit-open-group":                       false,
+	"mit-testregex":                        false,
+	"mit-wu":                               false,
+	"mitnfa":                               false,
+	"mmixware":                             false,
+	"motosoto":                             false,
+	"mpeg-ssg":                             false,
+	"mpi-permissive":                       false,
+	"mpich2":                               false,
+	"mpl-1.0":                              false,
+	"mpl-1.1":                              false,
+	"mpl-2.0":                              false,
+	"mpl-2.0-no-copyleft-exception":        false,
+	"mplus":                                false,
+	"ms-lpl":                               false,
+	"ms-pl":                                false,
+	"ms-rl":                                false,
+	"mtll":                                 false,
+	"mulanpsl-1.0":                         false,
+	"mulanpsl-2.0":                         false,
+	"multics":                              false,
+	"mup":                                  false,
+	"naist-2003":                           false,
+	"nasa-1.3":                             false,
+	"naumen":                               false,
+	"nbpl-1.0":                             false,
+	"ncbi-pd":                              false,
+	"ncgl-uk-2.0":                          false,
+	"ncl":                                  false,
+	"ncsa":                                 false,
+	"net-snmp":                             true,
+	"netcdf":                               false,
+	"newsletr":                             false,
+	"ngpl":                                 false,
+	"ngrep":                                false,
+	"nicta-1.0":                            false,
+	"nist-pd":                              false,
+	"nist-pd-fallback":                     false,
+	"nist-software":                        false,
+	"nlod-1.0":                             false,
+	"nlod-2.0":                             false,
+	"nlpl":                                 false,
+	"nokia":                                false,
+	"nosl":                                 false,
+	"noweb":                                false,
+	"npl-1.0":                              false,
+	"npl-1.1":                              false,
+	"nposl-3.0":                            false,
+	"nrl":                                  false,
+	"ntia-pd":                              false,
+	"ntp":                                  false,
+	"ntp-0":                                false,
+	"nunit":                                true,
+	"o-uda-1.0":                            false,
+	"oar":                                  false,
+	"occt-pl":                              false,
+	"oclc-2.0":                             false,
+	"odbl-1.0":                             false,
+	"odc-by-1.0":                           false,
+	"offis":                                false,
+	"ofl-1.0":                              false,
+	"ofl-1.0-no-rfn":                       false,
+	"ofl-1.0-rfn":                          false,
+	"ofl-1.1":                              false,
+	"ofl-1.1-no-rfn":                       false,
+	"ofl-1.1-rfn":                          false,
+	"ogc-1.0":                              false,
+	"ogdl-taiwan-1.0":                      false,
+	"ogl-canada-2.0":                       false,
+	"ogl-uk-1.0":                           false,
+	"ogl-uk-2.0":                           false,
+	"ogl-uk-3.0":                           false,
+	"ogtsl":                                false,
+	"oldap-1.1":                            false,
+	"oldap-1.2":                            false,
+	"oldap-1.3":                            false,
+	"oldap-1.4":                            false,
+	"oldap-2.0":                            false,
+	"oldap-2.0.1":                          false,
+	"oldap-2.1":                            false,
+	"oldap-2.2":                            false,
+	"oldap-2.2.1":                          false,
+	"oldap-2.2.2":                          false,
+	"oldap-2.3":                            false,
+	"oldap-2.4":                            false,
+	"oldap-2.5":                            false,
+	"oldap-2.6":                            false,
+	"oldap-2.7":                            false,
+	"oldap-2.8":                            false,
+	"olfl-1.3":                             false,
+	"oml":                                  false,
+	"openpbs-2.3":                          false,
+	"openssl":                              false,
+	"openssl-standalone":                   false,
+	"openvision":                           false,
+	"opl-1.0":                              false,
+	"opl-uk-3.0":                           false,
+	"opubl-1.0":                            false,
+	"oset-pl-2.1":                          false,
+	"osl-1.0":                              false,
+	"osl-1.1":                              false,
+	"osl-2.0":                              false,
+	"osl-2.1":                              false,
+	"osl-3.0":                              false,
+	"padl":                                 false,
+	"parity-6.0.0":                         false,
+	"parity-7.0.0":                         false,
+	"pddl-1.0":                             false,
+	"php-3.0":                              false,
+	"php-3.01":                             false,
+	"pixar":                                false,
+	"pkgconf":                              false,
+	"plexus":                               false,
+	"pnmstitch":                            false,
+	"polyform-noncommercial-1.0.0":         false,
+	"polyform-small-business-1.0.0":        false,
+	"postgresql":                           false,
+	"ppl":                                  false,
+	"psf-2.0":                              false,
+	"psfrag":                               false,
+	"psutils":                              false,
+	"python-2.0":                           false,
+	"python-2.0.1":                         false,
+	"python-ldap":                          false,
+	"qhull":                                false,
+	"qpl-1.0":                              false,
+	"qpl-1.0-inria-2004":                   false,
+	"radvd":                                false,
+	"rdisc":                                false,
+	"rhecos-1.1":                           false,
+	"rpl-1.1":                              false,
+	"rpl-1.5":                              false,
+	"rpsl-1.0":                             false,
+	"rsa-md":                               false,
+	"rscpl":                                false,
+	"ruby":                                 false,
+	"ruby-pty":                             false,
+	"sax-pd":                               false,
+	"sax-pd-2.0":                           false,
+	"saxpath":                              false,
+	"scea":                                 false,
+	"schemereport":                         false,
+	"sendmail":                             false,
+	"sendmail-8.23":                        false,
+	"sendmail-open-source-1.1":             false,
+	"sgi-b-1.0":                            false,
+	"sgi-b-1.1":                            false,
+	"sgi-b-2.0":                            false,
+	"sgi-opengl":                           false,
+	"sgp4":                                 false,
+	"shl-0.5":                              false,
+	"shl-0.51":                             false,
+	"simpl-2.0":                            false,
+	"sissl":                                false,
+	"sissl-1.2":                            false,
+	"sl":                                   false,
+	"sleepycat":                            false,
+	"smail-gpl":                            false,
+	"smlnj":                                false,
+	"smppl":                                false,
+	"snia":                                 false,
+	"snprintf":                             false,
+	"sofa":                                 false,
+	"softsurfer":                           false,
+	"soundex":                              false,
+	"spencer-86":                           false,
+	"spencer-94":                           false,
+	"spencer-99":                           false,
+	"spl-1.0":                              false,
+	"ssh-keyscan":                          false,
+	"ssh-openssh":                          false,
+	"ssh-short":                            false,
+	"ssleay-standalone":                    false,
+	"sspl-1.0":                             false,
+	"standardml-nj":                        true,
+	"sugarcrm-1.1.3":                       false,
+	"sul-1.0":                              false,
+	"sun-ppp":                              false,
+	"sun-ppp-2000":                         false,
+	"sunpro":                               false,
+	"swl":                                  false,
+	"swrule":                               false,
+	"symlinks":                             false,
+	"tapr-ohl-1.0":                         false,
+	"tcl":                                  false,
+	"tcp-wrappers":                         false,
+	"termreadkey":                          false,
+	"tgppl-1.0":                            false,
+	"thirdeye":                             false,
+	"threeparttable":                       false,
+	"tmate":                                false,
+	"torque-1.1":                           false,
+	"tosl":                                 false,
+	"tpdl":                                 false,
+	"tpl-1.0":                              false,
+	"trustedqsl":                           false,
+	"ttwl":                                 false,
+	"ttyp0":                                false,
+	"tu-berlin-1.0":                        false,
+	"tu-berlin-2.0":                        false,
+	"ubuntu-font-1.0":                      false,
+	"ucar":                                 false,
+	"ucl-1.0":                              false,
+	"ulem":                                 false,
+	"umich-merit":                          false,
+	"unicode-3.0":                          false,
+	"unicode-dfs-2015":                     false,
+	"unicode-dfs-2016":                     false,
+	"unicode-tou":                          false,
+	"unixcrypt":                            false,
+	"unlicense":                            false,
+	"unlicense-libtelnet":                  false,
+	"unlicense-libwhirlpool":               false,
+	"upl-1.0":                              false,
+	"urt-rle":                              false,
+	"vim":                                  false,
+	"vostrom":                              false,
+	"vsl-1.0":                              false,
+	"w3c":                                  false,
+	"w3c-19980720":                         false,
+	"w3c-20150513":                         false,
+	"w3m":                                  false,
+	"watcom-1.0":                           false,
+	"widget-workshop":                      false,
+	"wsuipa":                               false,
+	"wtfpl":                                false,
+	"wwl":                                  false,
+	"wxwindows":                            true,
+	"x11":                                  false,
+	"x11-distribute-modifications-variant": false,
+	"x11-swapped":                          false,
+	"xdebug-1.03":                          false,
+	"xerox":                                false,
+	"xfig":                                 false,
+	"xfree86-1.1":                          false,
+	"xinetd":                               false,
+	"xkeyboard-config-zinoviev":            false,
+	"xlock":                                false,
+	"xnet":                                 false,
+	"xpp":                                  false,
+	"xskat":                                false,
+	"xzoom":                                false,
+	"ypl-1.0":                              false,
+	"ypl-1.1":                              false,
+	"zed":                                  false,
+	"zeeff":                                false,
+	"zend-2.0":                             false,
+	"zimbra-1.3":                           false,
+	"zimbra-1.4":                           false,
+	"zlib":                                 false,
+	"zlib-acknowledgement":                 false,
+	"zpl-1.1":                              false,
+	"zpl-2.0":                              false,
+	"zpl-2.1":                              false,
+}
+
+// Identifiers (lowercased) of the SPDX exception list, with their deprecated flag
+var exceptions = map[string]bool{
+	"389-exception":                        false,
+	"asterisk-exception":                   false,
+	"asterisk-linking-protocols-exception": false,
+	"autoconf-exception-2.0":               false,
+	"autoconf-exception-3.0":               false,
+	"autoconf-exception-generic":           false,
+	"autoconf-exception-generic-3.0":       false,
+	"autoconf-exception-macro":             false,
+	"bison-exception-1.24":                 false,
+	"bison-exception-2.2":                  false,
+	"bootloader-exception":                 false,
+	"cgal-linking-exception":               false,
+	"classpath-exception-2.0":              false,
+	"clisp-exception-2.0":                  false,
+	"cryptsetup-openssl-exception":         false,
+	"digia-qt-lgpl-exception-1.1":          false,
+	"digirule-foss-exception":              false,
+	"ecos-exception-2.0":                   false,
+	"erlang-otp-linking-exception":         false,
+	"fawkes-runtime-exception":             false,
+	"fltk-exception":                       false,
+	"fmt-exception":                        false,
+	"font-exception-2.0":                   false,
+	"freertos-exception-2.0":               false,
+	"gcc-exception-2.0":                    false,
+	"gcc-exception-2.0-note":               false,
+	"gcc-exception-3.1":                    false,
+	"gmsh-exception":                       false,
+	"gnat-exception":                       false,
+	"gnome-examples-exception":             false,
+	"gnu-compiler-exception":               false,
+	"gnu-javamail-exception":               false,
+	"gpl-3.0-389-ds-base-exception":        false,
+	"gpl-3.0-interface-exception":          false,
+	"gpl-3.0-linking-exception":            false,
+	"gpl-3.0-linking-source-exception":     false,
+	"gpl-cc-1.0":                           false,
+	"gstreamer-exception-2005":             false,
+	"gstreamer-exception-2008":             false,
+	"harbour-exception":                    false,
+	"i2p-gpl-java-exception":               false,
+	"independent-modules-exception":        false,
+	"kicad-libraries-exception":            false,
+	"lgpl-3.0-linking-exception":           false,
+	"libpri-openh323-exception":            false,
+	"libtool-exception":                    false,
+	"linux-syscall-note":                   false,
+	"llgpl":                                false,
+	"llvm-exception":                       false,
+	"lzma-exception":                       false,
+	"mif-exception":                        false,
+	"mxml-exception":                       false,
+	"nokia-qt-exception-1.1":               true,
+	"ocaml-lgpl-linking-exception":         false,
+	"occt-exception-1.0":                   false,
+	"openjdk-assembly-exception-1.0":       false,
+	"openvpn-openssl-exception":            false,
+	"pcre2-exception":                      false,
+	"polyparse-exception":                  false,
+	"ps-or-pdf-font-exception-20170817":    false,
+	"qpl-1.0-inria-2004-exception":         false,
+	"qt-gpl-exception-1.0":                 false,
+	"qt-lgpl-exception-1.1":                false,
+	"qwt-exception-1.0":                    false,
+	"romic-exception":                      false,
+	"rrdtool-floss-exception-2.0":          false,
+	"sane-exception":                       false,
+	"shl-2.0":                              false,
+	"shl-2.1":                              false,
+	"stunnel-exception":                    false,
+	"swi-exception":                        false,
+	"swift-exception":                      false,
+	"texinfo-exception":                    false,
+	"u-boot-exception-2.0":                 false,
+	"ubdl-exception":                       false,
+	"universal-foss-exception-1.0":         false,
+	"vsftpd-openssl-exception":             false,
+	"wxwindows-exception-3.1":              false,
+	"x11vnc-openssl-exception":             false,
+}
@@ -0,0 +1,96 @@
+package spdx
+
+//go:generate go run gen.go
+
+import (
+	"strings"
+)
+
+// Status of a license identifier
+const (
+	Valid      = "valid"      // identifier of the SPDX license list
+	Deprecated = "deprecated" // deprecated identifier of the SPDX license list
+	Custom     = "custom"     // custom license (custom:…, LicenseRef-…), which needs a license file
+	Unknown    = "unknown"    // not an SPDX identifier
+)
+
+// Identifiers returns the license identifiers
+// and the exceptions of an SPDX license expression.
+// The legacy custom licenses of pacman (custom or custom:<name>)
+// are returned as a single identifier.
+func Identifiers(expr string) (licenses, exceptions []string) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return
+	}
+	if isLegacyCustom(expr) {
+		return []string{expr}, nil
+	}
+
+	r := strings.NewReplacer("(", " ", ")", " ")
+	fields := strings.Fields(r.Replace(expr))
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR":
+		case "WITH":
+			if i+1 < len(fields) {
+				i++
+				exceptions = append(exceptions, fields[i])
+			}
+		default:
+			licenses = append(licenses, fields[i])
+		}
+	}
+
+	return
+}
+
+func isLegacyCustom(id string) bool {
+	id = strings.ToLower(id)
+
+	return id == "custom" || strings.HasPrefix(id, "custom:")
+}
+
+// Status returns the status of the license identifier.
+// The identifiers with the + suffix (or later versions)
+// which aren’t in the list are checked without it.
+func Status(id string) string {
+	if isLegacyCustom(id) || strings.HasPrefix(id, "LicenseRef-") || strings.HasPrefix(id, "DocumentRef-") {
+		return Custom
+	}
+
+	id = strings.ToLower(id)
+	deprecated, ok := licenses[id]
+	if !ok {
+		deprecated, ok = licenses[strings.TrimSuffix(id, "+")]
+	}
+
+	return listStatus(deprecated, ok)
+}
+
+// ExceptionStatus returns the status of the exception identifier.
+func ExceptionStatus(id string) string {
+	if strings.HasPrefix(id, "AdditionRef-") {
+		return Custom
+	}
+
+	deprecated, ok := exceptions[strings.ToLower(id)]
+
+	return listStatus(deprecated, ok)
+}
+
+func listStatus(deprecated, ok bool) string {
+	if !ok {
+		return Unknown
+	} else if deprecated {
+		return Deprecated
+	}
+
+	return Valid
+}
+
+// IsException returns true if the identifier
+// is an exception of the SPDX exception list.
+func IsException(id string) bool {
+	return ExceptionStatus(id) != Unknown
+}
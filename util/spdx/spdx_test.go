@@ -0,0 +1,45 @@
+package spdx
+
+import (
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		id, want string
+	}{
+		{"MIT", Valid},
+		{"mit", Valid},
+		{"GPL-2.0-or-later", Valid},
+		{"Apache-2.0+", Valid},
+		{"GPL-2.0", Deprecated},
+		{"GPL-2.0+", Deprecated},
+		{"custom:foo", Custom},
+		{"LicenseRef-foo", Custom},
+		{"GPL2", Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := Status(tt.id); got != tt.want {
+			t.Errorf("Status(%q) = %s, want %s", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestExceptionStatus(t *testing.T) {
+	tests := []struct {
+		id, want string
+	}{
+		{"Classpath-exception-2.0", Valid},
+		{"llvm-exception", Valid},
+		{"Nokia-Qt-exception-1.1", Deprecated},
+		{"AdditionRef-foo", Custom},
+		{"MIT", Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := ExceptionStatus(tt.id); got != tt.want {
+			t.Errorf("ExceptionStatus(%q) = %s, want %s", tt.id, got, tt.want)
+		}
+	}
+}